@@ -0,0 +1,168 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Sample describes how a task that got room in a Limiter went.
+type Sample struct {
+	// Latency is how long the task ran for.
+	Latency time.Duration
+
+	// InFlight is the total weight running in the Limiter when the task
+	// finished, including its own.
+	InFlight int
+
+	// Err is the error the task returned.
+	Err error
+}
+
+// dropped reports whether the sample says nothing about the load on whatever
+// tasks are calling, because the task was canceled by its caller.
+func (s Sample) dropped() bool {
+	return errors.Is(s.Err, context.Canceled) || errors.Is(s.Err, ErrCanceled)
+}
+
+// LimitAlgorithm adjusts a Limiter's limit as tasks finish. A LimitAlgorithm
+// is called with its Limiter's lock held, so it must be quick, and it
+// mustn't be shared between Limiters if it keeps state.
+type LimitAlgorithm interface {
+	// Update returns the limit to use from now on, given the current one
+	// and how a task went.
+	Update(limit int, s Sample) int
+}
+
+// Adaptive makes a Limiter adjust its limit with alg, starting from the limit
+// given to NewLimiter, or 1 if that isn't positive.
+func Adaptive(alg LimitAlgorithm) LimiterOption {
+	return func(l *Limiter) {
+		l.alg = alg
+		if l.limit <= 0 {
+			l.limit = 1
+		}
+	}
+}
+
+// AIMD is a LimitAlgorithm that adds one to the limit when a task succeeds
+// while the limit is being put to use, and multiplies it by Backoff when a
+// task fails or takes longer than Threshold.
+type AIMD struct {
+	// Min and Max bound the limit. Min defaults to 1, and a Max of zero
+	// means no bound.
+	Min, Max int
+
+	// Threshold is the latency above which a task counts as failed. Zero
+	// means latency is ignored.
+	Threshold time.Duration
+
+	// Backoff defaults to 0.9.
+	Backoff float64
+}
+
+// Update implements LimitAlgorithm.
+func (a AIMD) Update(limit int, s Sample) int {
+	if s.dropped() {
+		return limit
+	}
+	if s.Err != nil || (a.Threshold > 0 && s.Latency > a.Threshold) {
+		backoff := a.Backoff
+		if backoff <= 0 || backoff >= 1 {
+			backoff = 0.9
+		}
+		limit = int(float64(limit) * backoff)
+	} else if s.InFlight*2 >= limit {
+		// Only grow when tasks are using at least half of the limit, or
+		// a quiet period would let it grow without bound.
+		limit++
+	}
+	return clampLimit(limit, a.Min, a.Max)
+}
+
+// Gradient is a LimitAlgorithm that compares recent latency with its long
+// term average, in the style of TCP Vegas. While the two are close, the limit
+// grows by about its square root, to leave room for a queue; as recent
+// latency rises above the average, the limit shrinks in proportion, by at
+// most half per update. Errors halve the limit. Use a new Gradient for each
+// Limiter; the zero value is ready to use.
+type Gradient struct {
+	// Min and Max bound the limit. Min defaults to 1, and a Max of zero
+	// means no bound.
+	Min, Max int
+
+	// Tolerance is how many times the average latency recent latency may
+	// reach before the limit shrinks. It defaults to 1.5.
+	Tolerance float64
+
+	// Smoothing is how much each update moves the limit towards its new
+	// target, between 0 and 1. It defaults to 0.2.
+	Smoothing float64
+
+	short, long float64
+	estimate    float64
+}
+
+// Update implements LimitAlgorithm.
+func (g *Gradient) Update(limit int, s Sample) int {
+	if s.dropped() {
+		return limit
+	}
+	tolerance := g.Tolerance
+	if tolerance <= 0 {
+		tolerance = 1.5
+	}
+	smoothing := g.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if g.estimate == 0 {
+		g.estimate = float64(limit)
+	}
+
+	gradient := 0.5
+	if s.Err == nil {
+		latency := float64(s.Latency)
+		if g.long == 0 {
+			g.short, g.long = latency, latency
+		}
+		g.short += (latency - g.short) * 0.5
+		g.long += (latency - g.long) * 0.05
+		if g.short > 0 {
+			gradient = math.Max(0.5, math.Min(1, tolerance*g.long/g.short))
+		} else {
+			gradient = 1
+		}
+	}
+
+	target := g.estimate*gradient + math.Sqrt(g.estimate)
+	if gradient < 1 {
+		// Leave out the queue allowance while latency is rising.
+		target = g.estimate * gradient
+	}
+	g.estimate += (target - g.estimate) * smoothing
+
+	min, max := float64(g.Min), float64(g.Max)
+	if min < 1 {
+		min = 1
+	}
+	g.estimate = math.Max(g.estimate, min)
+	if max > 0 {
+		g.estimate = math.Min(g.estimate, max)
+	}
+	return clampLimit(int(g.estimate), g.Min, g.Max)
+}
+
+func clampLimit(limit, min, max int) int {
+	if min < 1 {
+		min = 1
+	}
+	if limit < min {
+		limit = min
+	}
+	if max > 0 && limit > max {
+		limit = max
+	}
+	return limit
+}
@@ -0,0 +1,63 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMD(t *testing.T) {
+	a := AIMD{Min: 2, Max: 5, Threshold: time.Second}
+
+	assert.Equal(t, 5, a.Update(4, Sample{Latency: time.Millisecond, InFlight: 4}))
+	assert.Equal(t, 5, a.Update(5, Sample{Latency: time.Millisecond, InFlight: 5}))
+	// Not using the limit, so there's no reason to raise it.
+	assert.Equal(t, 4, a.Update(4, Sample{Latency: time.Millisecond, InFlight: 1}))
+
+	assert.Equal(t, 3, a.Update(4, Sample{Err: someError}))
+	assert.Equal(t, 3, a.Update(4, Sample{Latency: 2 * time.Second}))
+	assert.Equal(t, 2, a.Update(2, Sample{Err: someError}))
+
+	assert.Equal(t, 4, a.Update(4, Sample{Err: context.Canceled}))
+	assert.Equal(t, 4, a.Update(4, Sample{Err: ErrCanceled}))
+}
+
+func TestGradient(t *testing.T) {
+	g := &Gradient{Max: 100}
+
+	limit := 10
+	for i := 0; i < 200; i++ {
+		limit = g.Update(limit, Sample{Latency: 10 * time.Millisecond, InFlight: limit})
+	}
+	assert.Equal(t, 100, limit, "steady latency should raise the limit")
+
+	for i := 0; i < 10; i++ {
+		limit = g.Update(limit, Sample{Latency: 100 * time.Millisecond, InFlight: limit})
+	}
+	assert.True(t, limit < 50, "rising latency should cut the limit, got %d", limit)
+
+	before := limit
+	limit = g.Update(limit, Sample{Err: someError})
+	assert.True(t, limit < before)
+
+	assert.Equal(t, limit, g.Update(limit, Sample{Err: context.Canceled}))
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := NewLimiter(2, Adaptive(AIMD{Max: 4}))
+	assert.Equal(t, 2, l.Limit())
+
+	e := NewExecutor(WithLimiter(l))
+	slowish := func() (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return nil, nil
+	}
+	e.Paralyze(slowish, slowish, slowish, slowish, slowish, slowish)
+	assert.Equal(t, 4, l.Limit())
+
+	e.ParalyzeLimit(1, errFn, errFn, errFn, errFn, errFn, errFn, errFn)
+	assert.Equal(t, 1, l.Limit())
+	assert.Equal(t, 0, l.Running())
+}
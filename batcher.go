@@ -0,0 +1,158 @@
+package paralyze
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads many keys at once, e.g. with a single multi-get. It must
+// return one ResErr per key, in the same order as keys, or an error that
+// applies to all of them.
+type BatchFunc func(ctx context.Context, keys []string) ([]ResErr, error)
+
+// Batcher coalesces individual calls for single keys into calls to a
+// BatchFunc, like a dataloader. Calls are collected until Wait has passed
+// since the first of them, or until MaxSize distinct keys are waiting,
+// whichever comes first. Calls for the same key in a batch share a result.
+type Batcher struct {
+	// Func loads a batch of keys. It must be set.
+	Func BatchFunc
+
+	// Wait is how long to collect calls for before running a batch.
+	Wait time.Duration
+
+	// MaxSize, if positive, runs a batch as soon as it has that many keys.
+	MaxSize int
+
+	// Clock is used to time Wait. If nil, the system clock is used.
+	Clock Clock
+
+	mu      sync.Mutex
+	pending *keyBatch
+}
+
+type keyBatch struct {
+	ctx        context.Context
+	keys       []string
+	index      map[string]int
+	waiters    int
+	timer      Timer
+	dispatched bool
+	cancel     context.CancelFunc
+
+	done    chan struct{}
+	results []ResErr
+	err     error
+	panik   interface{}
+}
+
+// Load returns the result for key, running it as part of the next batch. If
+// ctx is done first, Load returns ctx.Err(); a batch that every caller has
+// given up on is canceled, or never run if it hasn't started yet.
+//
+// The batch function runs with a context that keeps the values of the
+// first caller's ctx.
+func (b *Batcher) Load(ctx context.Context, key string) (interface{}, error) {
+	b.mu.Lock()
+	kb := b.pending
+	if kb == nil {
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		kb = &keyBatch{
+			ctx:    runCtx,
+			cancel: cancel,
+			index:  make(map[string]int),
+			done:   make(chan struct{}),
+		}
+		b.pending = kb
+		kb.timer = b.clock().AfterFunc(b.Wait, func() { b.dispatch(kb) })
+	}
+	i, ok := kb.index[key]
+	if !ok {
+		i = len(kb.keys)
+		kb.index[key] = i
+		kb.keys = append(kb.keys, key)
+	}
+	kb.waiters++
+	full := b.MaxSize > 0 && len(kb.keys) >= b.MaxSize
+	if full {
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	if full {
+		kb.timer.Stop()
+		go b.dispatch(kb)
+	}
+
+	select {
+	case <-kb.done:
+	case <-ctx.Done():
+		b.mu.Lock()
+		kb.waiters--
+		select {
+		case <-kb.done:
+			// The results came in while giving up; use them after all.
+			b.mu.Unlock()
+		default:
+			if kb.waiters == 0 {
+				kb.cancel()
+				if b.pending == kb {
+					b.pending = nil
+					kb.timer.Stop()
+				}
+			}
+			b.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	if kb.panik != nil {
+		panic(kb.panik)
+	}
+	if kb.err != nil {
+		return nil, kb.err
+	}
+	return kb.results[i].Res, kb.results[i].Err
+}
+
+// Wrap returns a function that loads key through b, so individual loads can
+// be run in a batch such as ParalyzeWithContext and still be coalesced.
+func (b *Batcher) Wrap(key string) ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		return b.Load(ctx, key)
+	}
+}
+
+func (b *Batcher) dispatch(kb *keyBatch) {
+	b.mu.Lock()
+	if b.pending == kb {
+		b.pending = nil
+	}
+	if kb.dispatched || kb.waiters == 0 {
+		b.mu.Unlock()
+		return
+	}
+	kb.dispatched = true
+	b.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			kb.panik = r
+		}
+		kb.cancel()
+		close(kb.done)
+	}()
+	kb.results, kb.err = b.Func(kb.ctx, kb.keys)
+	if kb.err == nil && len(kb.results) != len(kb.keys) {
+		kb.err = fmt.Errorf("paralyze: batch function returned %d results for %d keys", len(kb.results), len(kb.keys))
+	}
+}
+
+func (b *Batcher) clock() Clock {
+	if b.Clock == nil {
+		return systemClock{}
+	}
+	return b.Clock
+}
@@ -0,0 +1,121 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingBatchFunc returns a BatchFunc that records the keys of each batch
+// and loads every key as itself, except for "bad".
+func recordingBatchFunc(batches *[][]string, mu *sync.Mutex) BatchFunc {
+	return func(ctx context.Context, keys []string) ([]ResErr, error) {
+		mu.Lock()
+		*batches = append(*batches, append([]string(nil), keys...))
+		mu.Unlock()
+		results := make([]ResErr, len(keys))
+		for i, key := range keys {
+			if key == "bad" {
+				results[i].Err = someError
+				continue
+			}
+			results[i].Res = key
+		}
+		return results, nil
+	}
+}
+
+// waitForPending blocks until b's pending batch has n waiters.
+func waitForPending(b *Batcher, n int) {
+	for {
+		b.mu.Lock()
+		ok := b.pending != nil && b.pending.waiters == n
+		b.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	clock := &manualClock{}
+	b := &Batcher{Func: recordingBatchFunc(&batches, &mu), Wait: time.Millisecond, Clock: clock}
+
+	keys := []string{"a", "b", "a", "bad"}
+	results := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			results[i], errs[i] = b.Load(context.Background(), key)
+		}(i, key)
+	}
+	waitForPending(b, 4)
+	clock.fire()
+	wg.Wait()
+
+	assert.Equal(t, 1, len(batches))
+	assert.ElementsMatch(t, []string{"a", "b", "bad"}, batches[0])
+	assert.Equal(t, []interface{}{"a", "b", "a", nil}, results)
+	assert.Equal(t, []error{nil, nil, nil, someError}, errs)
+}
+
+func TestBatcherMaxSize(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	b := &Batcher{Func: recordingBatchFunc(&batches, &mu), Wait: time.Hour, MaxSize: 2}
+
+	results, errs := ParalyzeWithContext(context.Background(), b.Wrap("a"), b.Wrap("b"))
+	assert.Equal(t, []interface{}{"a", "b"}, results)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, 1, len(batches))
+}
+
+func TestBatcherError(t *testing.T) {
+	errDown := errors.New("down")
+	b := &Batcher{
+		Func: func(ctx context.Context, keys []string) ([]ResErr, error) { return nil, errDown },
+		Wait: time.Millisecond,
+	}
+	_, err := b.Load(context.Background(), "a")
+	assert.Equal(t, errDown, err)
+
+	b.Func = func(ctx context.Context, keys []string) ([]ResErr, error) { return nil, nil }
+	_, err = b.Load(context.Background(), "a")
+	assert.EqualError(t, err, "paralyze: batch function returned 0 results for 1 keys")
+}
+
+func TestBatcherCancel(t *testing.T) {
+	clock := &manualClock{}
+	called := false
+	b := &Batcher{
+		Func: func(ctx context.Context, keys []string) ([]ResErr, error) {
+			called = true
+			return make([]ResErr, len(keys)), nil
+		},
+		Wait:  time.Millisecond,
+		Clock: clock,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := b.Load(ctx, "a")
+		done <- err
+	}()
+	waitForPending(b, 1)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-done)
+	clock.fire()
+	assert.False(t, called)
+}
@@ -1,6 +1,10 @@
 package paralyze
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+)
 
 var (
 	fasterFn = func() (interface{}, error) { return 55, nil }
@@ -31,3 +35,84 @@ func BenchmarkWithoutChannelAlloc(test *testing.B) {
 		)
 	}
 }
+
+// makespanTasks are a batch of short jobs and a long one, submitted last.
+func makespanTasks() []Task {
+	sleep := func(d time.Duration) ParalyzableCtx {
+		return func(context.Context) (interface{}, error) {
+			time.Sleep(d)
+			return nil, nil
+		}
+	}
+	var tasks []Task
+	for i := 0; i < 8; i++ {
+		tasks = append(tasks, Task{Name: "short", Fn: sleep(time.Millisecond)})
+	}
+	return append(tasks, Task{Name: "long", Fn: sleep(4 * time.Millisecond)})
+}
+
+// BenchmarkMakespan compares how long a bounded batch takes to finish when
+// tasks start in order and when the longest start first. With two at a time,
+// the first takes about 8ms: 4ms of short tasks, then the long one alone. The
+// second takes about 6ms, with the short tasks running beside the long one.
+func BenchmarkMakespan(b *testing.B) {
+	est := &Estimates{}
+	est.Observe("short", time.Millisecond)
+	est.Observe("long", 4*time.Millisecond)
+
+	for _, bm := range []struct {
+		name   string
+		policy Policy
+	}{
+		{"FIFO", FIFO()},
+		{"LongestFirst", LongestFirst(est)},
+	} {
+		e := NewExecutor(WithPolicy(bm.policy))
+		b.Run(bm.name, func(b *testing.B) {
+			var total time.Duration
+			for i := 0; i < b.N; i++ {
+				start := time.Now()
+				e.Run(context.Background(), 2, makespanTasks()...)
+				total += time.Since(start)
+			}
+			b.ReportMetric(float64(total.Microseconds())/float64(b.N)/1000, "makespan-ms")
+		})
+	}
+}
+
+// BenchmarkForkJoin compares a recursive sum on a Pool with the same sum
+// splitting itself with Paralyze.
+func BenchmarkForkJoin(b *testing.B) {
+	xs := make([]int, 1<<14)
+	for i := range xs {
+		xs[i] = i
+	}
+
+	b.Run("Pool", func(b *testing.B) {
+		p := NewPool(0)
+		defer p.Close()
+		for i := 0; i < b.N; i++ {
+			p.Invoke(context.Background(), sum(xs))
+		}
+	})
+
+	var split func(xs []int) Paralyzable
+	split = func(xs []int) Paralyzable {
+		return func() (interface{}, error) {
+			if len(xs) <= 4 {
+				total := 0
+				for _, x := range xs {
+					total += x
+				}
+				return total, nil
+			}
+			results, _ := Paralyze(split(xs[:len(xs)/2]), split(xs[len(xs)/2:]))
+			return results[0].(int) + results[1].(int), nil
+		}
+	}
+	b.Run("Paralyze", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			split(xs)()
+		}
+	})
+}
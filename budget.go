@@ -0,0 +1,86 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineCtx reports a deadline without enforcing it, for batches that time
+// out by their Executor's Clock rather than the system's.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// withDeadline returns ctx with deadline as its deadline, unless it already
+// has an earlier one. It's up to the caller to cancel ctx once it passes.
+func withDeadline(ctx context.Context, deadline time.Time) context.Context {
+	if d, ok := ctx.Deadline(); ok && !deadline.Before(d) {
+		return ctx
+	}
+	return deadlineCtx{ctx, deadline}
+}
+
+// timeoutCtx is like a context made by context.WithDeadlineCause, but times
+// out by an Executor's Clock: once it does, Err returns
+// context.DeadlineExceeded and context.Cause returns ErrTimedOut.
+type timeoutCtx struct {
+	// Context is canceled along with the timeoutCtx, with its cause.
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu       sync.Mutex
+	timedOut bool
+	err      error
+}
+
+// withTimeout returns a copy of ctx that times out after d by c. Its done
+// channel is its own, so contexts derived from it take their error from Err
+// rather than from the context embedded in it.
+func withTimeout(ctx context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	inner, cancel := context.WithCancelCause(ctx)
+	t := &timeoutCtx{Context: inner, deadline: c.Now().Add(d), done: make(chan struct{})}
+	if pd, ok := ctx.Deadline(); ok && pd.Before(t.deadline) {
+		t.deadline = pd
+	}
+
+	context.AfterFunc(inner, func() {
+		t.mu.Lock()
+		t.err = inner.Err()
+		if t.timedOut {
+			t.err = context.DeadlineExceeded
+		}
+		t.mu.Unlock()
+		close(t.done)
+	})
+	timer := c.AfterFunc(d, func() {
+		t.mu.Lock()
+		t.timedOut = true
+		t.mu.Unlock()
+		cancel(ErrTimedOut)
+	})
+	return t, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
+
+func (t *timeoutCtx) Deadline() (time.Time, bool) {
+	return t.deadline, true
+}
+
+func (t *timeoutCtx) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *timeoutCtx) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
@@ -0,0 +1,113 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetNested(t *testing.T) {
+	clock := &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	e := NewExecutor(WithClock(clock), WithSafetyMargin(10*time.Millisecond))
+
+	var outer, inner time.Time
+	_, errs := e.ParalyzeWithBudget(context.Background(), 100*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+		outer, _ = ctx.Deadline()
+		_, errs := e.ParalyzeWithBudget(ctx, time.Hour, func(ctx context.Context) (interface{}, error) {
+			inner, _ = ctx.Deadline()
+			return nil, nil
+		})
+		return nil, errs[0]
+	})
+	assert.Equal(t, []error{nil}, errs)
+	assert.Equal(t, clock.now.Add(100*time.Millisecond), outer)
+	assert.Equal(t, clock.now.Add(90*time.Millisecond), inner)
+}
+
+func TestBudgetRefused(t *testing.T) {
+	clock := &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	e := NewExecutor(WithClock(clock), WithSafetyMargin(10*time.Millisecond))
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(5*time.Millisecond))
+	defer cancel()
+
+	ran := false
+	_, errs := e.ParalyzeWithBudget(ctx, time.Second, func(context.Context) (interface{}, error) {
+		ran = true
+		return nil, nil
+	})
+	assert.Equal(t, []error{ErrTimedOut}, errs)
+	assert.False(t, ran)
+}
+
+func TestBudgetTimeout(t *testing.T) {
+	clock := &manualClock{}
+	e := NewExecutor(WithClock(clock))
+
+	release := make(chan struct{})
+	causes := make(chan error, 1)
+	ctxErrs := make(chan error, 1)
+	done := make(chan []error)
+	go func() {
+		_, errs := e.ParalyzeWithBudget(context.Background(), time.Second, func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+			ctxErrs <- ctx.Err()
+			<-release
+			return nil, nil
+		})
+		done <- errs
+	}()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		clock.mu.Lock()
+		n := len(clock.timers)
+		clock.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timer never set")
+		}
+	}
+	clock.fire()
+	assert.Equal(t, ErrTimedOut, <-causes)
+	assert.Equal(t, context.DeadlineExceeded, <-ctxErrs)
+	assert.Equal(t, []error{ErrTimedOut}, <-done)
+	close(release)
+}
+
+func TestTimeoutWithinBaseDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	e := NewExecutor(WithBaseContext(ctx))
+
+	start := time.Now()
+	_, errs := e.ParalyzeWithTimeout(time.Hour, fastFn, func() (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	})
+	assert.Equal(t, []error{nil, ErrTimedOut}, errs)
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+}
+
+func TestBudgetContextErr(t *testing.T) {
+	ctxErrs := make(chan error, 1)
+	_, errs := ParalyzeWithBudget(context.Background(), 10*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		ctxErrs <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	assert.True(t, isCanceled(errs[0]))
+	assert.Equal(t, context.DeadlineExceeded, <-ctxErrs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ParalyzeWithBudget(ctx, time.Hour, func(ctx context.Context) (interface{}, error) {
+		cancel()
+		<-ctx.Done()
+		ctxErrs <- ctx.Err()
+		return nil, nil
+	})
+	assert.Equal(t, context.Canceled, <-ctxErrs)
+}
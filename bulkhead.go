@@ -0,0 +1,44 @@
+package paralyze
+
+import "sync"
+
+var bulkheads struct {
+	mu sync.RWMutex
+	m  map[string]*Limiter
+}
+
+// SetBulkhead makes l the bulkhead for partition name, process-wide. Tasks
+// with that Partition wait for room in l before they start, on top of any
+// other limits, so a slow dependency can only tie up as much concurrency as
+// its bulkhead allows. Setting a nil Limiter removes the bulkhead.
+func SetBulkhead(name string, l *Limiter) {
+	bulkheads.mu.Lock()
+	defer bulkheads.mu.Unlock()
+	if l == nil {
+		delete(bulkheads.m, name)
+		return
+	}
+	if bulkheads.m == nil {
+		bulkheads.m = make(map[string]*Limiter)
+	}
+	bulkheads.m[name] = l
+}
+
+// SetBulkheads sets a bulkhead with a fixed limit for each partition in
+// limits, e.g. map[string]int{"database": 10, "search": 5}.
+func SetBulkheads(limits map[string]int) {
+	for name, limit := range limits {
+		SetBulkhead(name, NewLimiter(limit))
+	}
+}
+
+// Bulkhead returns the bulkhead for partition name, or nil if there is none,
+// in which case tasks in the partition only wait for other limits.
+func Bulkhead(name string) *Limiter {
+	if name == "" {
+		return nil
+	}
+	bulkheads.mu.RLock()
+	defer bulkheads.mu.RUnlock()
+	return bulkheads.m[name]
+}
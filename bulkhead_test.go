@@ -0,0 +1,75 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead(t *testing.T) {
+	SetBulkheads(map[string]int{"search": 1})
+	defer SetBulkhead("search", nil)
+	search := Bulkhead("search")
+	assert.Equal(t, 1, search.Limit())
+	assert.Nil(t, Bulkhead("database"))
+
+	global := NewLimiter(2)
+	e := NewExecutor(WithLimiter(global))
+
+	release := make(chan struct{})
+	slowSearch := func(context.Context) (interface{}, error) {
+		<-release
+		return "search", nil
+	}
+	db := func(context.Context) (interface{}, error) { return "db", nil }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.Run(context.Background(), 0,
+			Task{Fn: slowSearch, Partition: "search"},
+			Task{Fn: slowSearch, Partition: "search"},
+			Task{Fn: db, Partition: "database"},
+			Task{Fn: db},
+		)
+		assert.Equal(t, []interface{}{"search", "search", "db", "db"}, results)
+		assert.Equal(t, []error{nil, nil, nil, nil}, errs)
+	}()
+
+	// The second search task waits in its bulkhead without holding a global
+	// slot, so the other tasks still get through.
+	waitForQueued(t, search, 1)
+	for deadline := time.Now().Add(time.Second); global.Running() != 1 || global.Queued() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("other partitions were held up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+	assert.Equal(t, 0, search.Running())
+	assert.Equal(t, 0, global.Running())
+}
+
+func TestBulkheadCanceled(t *testing.T) {
+	SetBulkhead("slow", NewLimiter(1))
+	defer SetBulkhead("slow", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := func(ctx context.Context) (interface{}, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	_, errs := ParalyzeTasks(ctx,
+		Task{Fn: blocked, Partition: "slow"},
+		Task{Fn: IgnoreContext(fastFn), Partition: "slow"},
+		Task{Fn: IgnoreContext(fastFn)},
+	)
+	assert.Equal(t, context.Canceled, errs[0])
+	assert.Equal(t, ErrCanceled, errs[1])
+	assert.Equal(t, 0, Bulkhead("slow").Running())
+}
@@ -0,0 +1,164 @@
+package paralyze
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores results for Memo. Implementations must be safe for concurrent
+// use.
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+	Delete(key string)
+}
+
+// CacheEntry is a task's result as stored in a Cache.
+type CacheEntry struct {
+	Res interface{}
+	Err error
+
+	// Created is when the result was computed.
+	Created time.Time
+}
+
+// LRUCache is an in-memory Cache that holds a limited number of entries,
+// evicting the least recently used one to make room for a new one.
+type LRUCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewLRUCache returns an LRUCache that holds at most size entries.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry for key and marks it as recently used.
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set stores entry under key, evicting the least recently used entry if the
+// cache is full.
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruItem).key)
+	}
+}
+
+// Delete removes the entry for key.
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// TTLCache is an in-memory Cache whose entries expire a fixed time after
+// they're set.
+type TTLCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu        sync.Mutex
+	entries   map[string]ttlItem
+	lastSweep time.Time
+}
+
+type ttlItem struct {
+	entry   CacheEntry
+	expires time.Time
+}
+
+// NewTTLCache returns a TTLCache whose entries expire ttl after they're set,
+// according to clock. If clock is nil, the system clock is used.
+func NewTTLCache(ttl time.Duration, clock Clock) *TTLCache {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &TTLCache{ttl: ttl, clock: clock, entries: make(map[string]ttlItem)}
+}
+
+// Get returns the entry for key, unless it has expired.
+func (c *TTLCache) Get(key string) (CacheEntry, bool) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.entries[key]
+	if !ok || !now.Before(item.expires) {
+		return CacheEntry{}, false
+	}
+	return item.entry, true
+}
+
+// Set stores entry under key until the cache's ttl has passed. Expired
+// entries are dropped along the way, at most once per ttl.
+func (c *TTLCache) Set(key string, entry CacheEntry) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for k, item := range c.entries {
+			if !now.Before(item.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = ttlItem{entry: entry, expires: now.Add(c.ttl)}
+}
+
+// Delete removes the entry for key.
+func (c *TTLCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Len returns the number of entries in the cache, including expired ones
+// that haven't been dropped yet.
+func (c *TTLCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,58 @@
+package paralyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", CacheEntry{Res: 1})
+	c.Set("b", CacheEntry{Res: 2})
+
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", CacheEntry{Res: 3})
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "b was least recently used")
+	entry, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, entry.Res)
+	assert.Equal(t, 2, c.Len())
+
+	c.Set("a", CacheEntry{Res: 10})
+	entry, _ = c.Get("a")
+	assert.Equal(t, 10, entry.Res)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestTTLCache(t *testing.T) {
+	clock := &manualClock{}
+	c := NewTTLCache(time.Minute, clock)
+	c.Set("a", CacheEntry{Res: 1})
+
+	clock.advance(30 * time.Second)
+	c.Set("b", CacheEntry{Res: 2})
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	clock.advance(30 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	clock.advance(time.Minute)
+	c.Set("c", CacheEntry{Res: 3})
+	assert.Equal(t, 1, c.Len(), "expired entries are swept")
+
+	c.Delete("c")
+	assert.Equal(t, 0, c.Len())
+}
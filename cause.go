@@ -0,0 +1,65 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CanceledError is the error of a task that was stopped, or never started,
+// for a reason other than the one its error alone would give. It matches
+// both Err and Cause with errors.Is.
+type CanceledError struct {
+	// Err is ErrCanceled or ErrTimedOut.
+	Err error
+
+	// Cause is why the task was stopped, as given to a context's
+	// CancelCauseFunc for example.
+	Cause error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.Cause)
+}
+
+func (e *CanceledError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// SiblingFailedError is the cause of a FailFast Group's cancellation, as
+// returned by context.Cause for the context passed to its tasks.
+type SiblingFailedError struct {
+	// Task is the index of the task that failed, counting the tasks
+	// started in its group with Go from zero.
+	Task int
+	Err  error
+}
+
+func (e *SiblingFailedError) Error() string {
+	return fmt.Sprintf("task %d failed: %v", e.Task, e.Err)
+}
+
+func (e *SiblingFailedError) Unwrap() error {
+	return e.Err
+}
+
+// canceledBy returns err, wrapped along with why ctx was canceled if that
+// says more than ctx.Err does.
+func canceledBy(ctx context.Context, err error) error {
+	cause := context.Cause(ctx)
+	if cause == nil || cause == ctx.Err() || errors.Is(cause, err) {
+		return err
+	}
+	return &CanceledError{Err: err, Cause: cause}
+}
+
+// canceledErr returns the error for tasks that are stopped because ctx is
+// done: ErrTimedOut if its deadline passed or it was canceled with
+// ErrTimedOut as the cause, or ErrCanceled otherwise.
+func canceledErr(ctx context.Context) error {
+	err := ErrCanceled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(context.Cause(ctx), ErrTimedOut) {
+		err = ErrTimedOut
+	}
+	return canceledBy(ctx, err)
+}
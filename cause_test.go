@@ -0,0 +1,94 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanceledCause(t *testing.T) {
+	l := NewLimiter(1)
+	e := NewExecutor(WithLimiter(l))
+	shutdown := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	blocked := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, context.Cause(ctx)
+	}
+	done := make(chan []error)
+	go func() {
+		_, errs := e.ParalyzeWithContext(ctx, blocked, IgnoreContext(fastFn))
+		done <- errs
+	}()
+
+	waitForQueued(t, l, 1)
+	cancel(shutdown)
+	errs := <-done
+
+	assert.Equal(t, shutdown, errs[0])
+	assert.True(t, errors.Is(errs[1], ErrCanceled))
+	assert.True(t, errors.Is(errs[1], shutdown))
+	var ce *CanceledError
+	assert.True(t, errors.As(errs[1], &ce))
+	assert.Equal(t, shutdown, ce.Cause)
+	assert.Equal(t, "canceled: shutting down", errs[1].Error())
+}
+
+func TestCanceledWithoutCause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, ErrCanceled, canceledBy(ctx, ErrCanceled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	assert.Equal(t, ErrTimedOut, canceledBy(ctx, ErrTimedOut))
+}
+
+func TestAbandonedCause(t *testing.T) {
+	causes := make(chan error, 1)
+	e := NewExecutor(WithMiddleware(func(info TaskInfo, fn ParalyzableCtx) ParalyzableCtx {
+		return func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+			return fn(ctx)
+		}
+	}))
+
+	_, errs := e.ParalyzeWithTimeout(time.Millisecond, fastFn)
+	assert.Equal(t, ErrTimedOut, errs[0])
+	assert.Equal(t, ErrTimedOut, <-causes)
+
+	cancel := make(chan struct{})
+	close(cancel)
+	_, errs = e.ParalyzeWithCancel(cancel, fastFn)
+	assert.Equal(t, ErrCanceled, errs[0])
+	assert.Equal(t, ErrCanceled, <-causes)
+}
+
+func TestGroupCause(t *testing.T) {
+	root := NewGroup(context.Background(), FailFast)
+	child := root.Child(CollectAll)
+
+	causes := make(chan error, 1)
+	child.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	})
+	root.Go(fastCtx)
+	root.Go(func(context.Context) error { return someError })
+
+	assert.Equal(t, someError, root.Wait())
+	cause := <-causes
+	var sfe *SiblingFailedError
+	assert.True(t, errors.As(cause, &sfe))
+	assert.Equal(t, 1, sfe.Task)
+	assert.True(t, errors.Is(cause, someError))
+	assert.Equal(t, "task 1 failed: some error", cause.Error())
+}
+
+func fastCtx(context.Context) error { return nil }
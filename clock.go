@@ -0,0 +1,29 @@
+package paralyze
+
+import "time"
+
+// Clock tells time for an Executor. It only needs replacing in tests; see
+// WithClock.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call made by a Clock's AfterFunc.
+type Timer interface {
+	// Stop prevents the call from happening. It reports whether it did so,
+	// i.e. false means the call already happened or was already stopped.
+	Stop() bool
+}
+
+// WithClock makes an Executor use c instead of the system clock, both for
+// timeouts and for the times it reports.
+func WithClock(c Clock) Option {
+	return func(e *Executor) { e.clock = c }
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
@@ -0,0 +1,172 @@
+package paralyze
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter records a task that failed for good, so that it can be looked
+// into or replayed later.
+type DeadLetter struct {
+	// Key is the task's Name or ParalyzeM key, if it had one.
+	Key string `json:"key,omitempty"`
+
+	// Index is the task's position in its batch. It's all there is to tell
+	// tasks apart by for batches of plain functions, such as ParalyzeLimit.
+	Index int `json:"index"`
+
+	// Input is the task's Input, so it's only set for batches of Tasks,
+	// such as Run. Read back from a DeadLetterFile, it's whatever
+	// encoding/json decodes it to; see DecodeInput.
+	Input interface{} `json:"input,omitempty"`
+
+	// Err is the message of the task's last error.
+	Err string `json:"error"`
+
+	// Attempts are the task's runs, in order.
+	Attempts []Attempt `json:"attempts"`
+
+	// Time is when the task was given up on.
+	Time time.Time `json:"time"`
+}
+
+// Attempt is one run of a task that failed.
+type Attempt struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Err      string        `json:"error"`
+}
+
+// DecodeInput stores the letter's Input in the value pointed to by v, going
+// through JSON so it works the same whether the letter was kept in memory or
+// read back from a file.
+func (l *DeadLetter) DecodeInput(v interface{}) error {
+	b, err := json.Marshal(l.Input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// DeadLetterSink receives the tasks that fail for good under a RetryPolicy.
+// Put may be called concurrently.
+type DeadLetterSink interface {
+	Put(l DeadLetter) error
+}
+
+// DeadLetterMemory keeps dead letters in memory. The zero value is ready to
+// use.
+type DeadLetterMemory struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// Put adds l.
+func (m *DeadLetterMemory) Put(l DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, l)
+	return nil
+}
+
+// Letters returns the letters put so far, oldest first.
+func (m *DeadLetterMemory) Letters() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeadLetter(nil), m.letters...)
+}
+
+// Drain returns the letters put so far, oldest first, and forgets them, e.g.
+// to replay them.
+func (m *DeadLetterMemory) Drain() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	letters := m.letters
+	m.letters = nil
+	return letters
+}
+
+// DeadLetterFile appends dead letters to a file as JSON lines, one letter per
+// line.
+type DeadLetterFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewDeadLetterFile returns a DeadLetterFile writing to path, which is
+// created when the first letter is put if it doesn't exist.
+func NewDeadLetterFile(path string) *DeadLetterFile {
+	return &DeadLetterFile{path: path}
+}
+
+// Put appends l to the file.
+func (f *DeadLetterFile) Put(l DeadLetter) error {
+	line, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	return errors.Join(err, file.Close())
+}
+
+// Letters reads back the letters in the file, oldest first. A file that
+// doesn't exist holds none.
+func (f *DeadLetterFile) Letters() ([]DeadLetter, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadDeadLetters(file)
+}
+
+// ReadDeadLetters reads dead letters written as JSON lines by a
+// DeadLetterFile from r.
+func ReadDeadLetters(r io.Reader) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return letters, err
+		}
+		letters = append(letters, l)
+	}
+	return letters, scanner.Err()
+}
+
+// Replay runs dead-lettered tasks again, at most limit at a time if limit is
+// positive, like Run. rebuild makes each letter back into the function to
+// run, from its Key and Input, which only tasks run as Tasks have. The
+// replayed tasks keep the letters' Key and Input, so with WithRetry the ones
+// that fail for good are dead-lettered again.
+func (e *Executor) Replay(ctx context.Context, limit int, letters []DeadLetter, rebuild func(DeadLetter) ParalyzableCtx) ([]interface{}, []error) {
+	tasks := make([]Task, len(letters))
+	for i, l := range letters {
+		tasks[i] = Task{Name: l.Key, Input: l.Input, Fn: rebuild(l)}
+	}
+	return e.Run(ctx, limit, tasks...)
+}
@@ -0,0 +1,150 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+// sendReceipt fails for the orders in bad.
+func sendReceipt(bad map[int]bool, sent *int32) func(order) ParalyzableCtx {
+	return func(o order) ParalyzableCtx {
+		return func(context.Context) (interface{}, error) {
+			if bad[o.ID] {
+				return nil, fmt.Errorf("order %d: mailbox full", o.ID)
+			}
+			atomic.AddInt32(sent, 1)
+			return o.ID, nil
+		}
+	}
+}
+
+func orderTasks(n int, send func(order) ParalyzableCtx) []Task {
+	var tasks []Task
+	for i := 0; i < n; i++ {
+		o := order{ID: i, Email: fmt.Sprintf("%d@example.com", i)}
+		tasks = append(tasks, Task{Name: fmt.Sprintf("receipt-%d", i), Input: o, Fn: send(o)})
+	}
+	return tasks
+}
+
+func TestDeadLetterMemory(t *testing.T) {
+	sink := &DeadLetterMemory{}
+	e := NewExecutor(WithRetry(RetryPolicy{Attempts: 2, DeadLetters: sink}))
+
+	var sent int32
+	_, errs := e.Run(context.Background(), 3, orderTasks(6, sendReceipt(map[int]bool{2: true, 4: true}, &sent))...)
+	assert.Equal(t, int32(4), sent)
+	assert.Error(t, errs[2])
+	assert.Error(t, errs[4])
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 2)
+	keys := []string{letters[0].Key, letters[1].Key}
+	assert.ElementsMatch(t, []string{"receipt-2", "receipt-4"}, keys)
+	for _, l := range letters {
+		assert.Len(t, l.Attempts, 2)
+		assert.Equal(t, l.Err, l.Attempts[1].Err)
+		assert.True(t, strings.HasSuffix(l.Err, "mailbox full"))
+		assert.Equal(t, order{ID: l.Index, Email: fmt.Sprintf("%d@example.com", l.Index)}, l.Input)
+	}
+
+	// Replaying once the mailboxes have room sends the rest.
+	_, errs = e.Replay(context.Background(), 0, sink.Drain(), func(l DeadLetter) ParalyzableCtx {
+		var o order
+		assert.NoError(t, l.DecodeInput(&o))
+		return sendReceipt(nil, &sent)(o)
+	})
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, int32(6), sent)
+	assert.Empty(t, sink.Letters())
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	sink := NewDeadLetterFile(filepath.Join(t.TempDir(), "dead.jsonl"))
+	letters, err := sink.Letters()
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+
+	e := NewExecutor(WithRetry(RetryPolicy{Attempts: 3, DeadLetters: sink}))
+	var sent int32
+	e.Run(context.Background(), 2, orderTasks(4, sendReceipt(map[int]bool{1: true}, &sent))...)
+
+	letters, err = sink.Letters()
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	l := letters[0]
+	assert.Equal(t, "receipt-1", l.Key)
+	assert.Equal(t, 1, l.Index)
+	assert.Equal(t, "order 1: mailbox full", l.Err)
+	assert.Len(t, l.Attempts, 3)
+	assert.False(t, l.Time.IsZero())
+
+	// Replayed tasks that fail again are dead-lettered again.
+	_, errs := e.Replay(context.Background(), 0, letters, func(l DeadLetter) ParalyzableCtx {
+		var o order
+		assert.NoError(t, l.DecodeInput(&o))
+		assert.Equal(t, "1@example.com", o.Email)
+		return sendReceipt(map[int]bool{1: true}, &sent)(o)
+	})
+	assert.Error(t, errs[0])
+	letters, err = sink.Letters()
+	assert.NoError(t, err)
+	assert.Len(t, letters, 2)
+	assert.Equal(t, "receipt-1", letters[1].Key)
+}
+
+type failingSink struct{}
+
+var errSink = errors.New("sink is down")
+
+func (failingSink) Put(DeadLetter) error { return errSink }
+
+func TestDeadLetterSinkFails(t *testing.T) {
+	e := NewExecutor(WithRetry(RetryPolicy{DeadLetters: failingSink{}}))
+	_, errs := e.Paralyze(errFn)
+	assert.True(t, errors.Is(errs[0], someError))
+	assert.True(t, errors.Is(errs[0], errSink))
+}
+
+func TestReadDeadLetters(t *testing.T) {
+	letters, err := ReadDeadLetters(strings.NewReader(
+		`{"key":"a","index":0,"error":"boom","attempts":[{"error":"boom"}]}` + "\n\n" +
+			`{"index":3,"input":{"id":7},"error":"bang","attempts":[]}` + "\n"))
+	assert.NoError(t, err)
+	assert.Len(t, letters, 2)
+	assert.Equal(t, "a", letters[0].Key)
+	assert.Equal(t, 3, letters[1].Index)
+
+	var o order
+	assert.NoError(t, letters[1].DecodeInput(&o))
+	assert.Equal(t, 7, o.ID)
+
+	_, err = ReadDeadLetters(strings.NewReader("not json\n"))
+	assert.Error(t, err)
+}
+
+func TestDeadLetterWithoutTasks(t *testing.T) {
+	// Plain functions have no Name or Input, only their index.
+	sink := &DeadLetterMemory{}
+	e := NewExecutor(WithRetry(RetryPolicy{Attempts: 2, DeadLetters: sink}))
+	e.ParalyzeLimit(2, fastFn, errFn)
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, 1, letters[0].Index)
+	assert.Empty(t, letters[0].Key)
+	assert.Nil(t, letters[0].Input)
+	assert.Len(t, letters[0].Attempts, 2)
+}
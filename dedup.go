@@ -0,0 +1,142 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Dedup makes concurrent calls with the same key share a single execution
+// and its result, e.g. so that many requests fetching the same user only hit
+// the backend once. The zero value is ready to use and forgets each key as
+// soon as its execution completes.
+type Dedup struct {
+	// TTL, if positive, keeps successful results around for that long so
+	// calls that arrive after an execution completes share it too. Errors
+	// are never kept.
+	TTL time.Duration
+
+	// Clock is used to expire results. If nil, the system clock is used.
+	Clock Clock
+
+	mu    sync.Mutex
+	calls map[string]*dedupCall
+}
+
+type dedupCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	res   interface{}
+	err   error
+	panik interface{}
+}
+
+// Do calls fn, unless a call with the same key is already running or its
+// result is still cached, in which case it waits for that result instead.
+// shared reports whether the result came from an execution started by
+// another call.
+//
+// fn runs with a context that keeps ctx's values but is only canceled once
+// every call waiting on it has given up. A call whose ctx is done before the
+// result is ready returns ctx.Err().
+func (d *Dedup) Do(ctx context.Context, key string, fn ParalyzableCtx) (res interface{}, err error, shared bool) {
+	d.mu.Lock()
+	if d.calls == nil {
+		d.calls = make(map[string]*dedupCall)
+	}
+	if c, ok := d.calls[key]; ok {
+		c.waiters++
+		d.mu.Unlock()
+		return d.wait(ctx, key, c, true)
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := &dedupCall{done: make(chan struct{}), cancel: cancel, waiters: 1}
+	d.calls[key] = c
+	d.mu.Unlock()
+
+	go d.run(runCtx, key, c, fn)
+	return d.wait(ctx, key, c, false)
+}
+
+// Wrap returns a function that calls fn through d.Do under key, for use in a
+// batch such as ParalyzeWithContext.
+func (d *Dedup) Wrap(key string, fn ParalyzableCtx) ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		res, err, _ := d.Do(ctx, key, fn)
+		return res, err
+	}
+}
+
+// Forget makes the next call with key start a new execution, even if one is
+// running or its result is cached.
+func (d *Dedup) Forget(key string) {
+	d.mu.Lock()
+	delete(d.calls, key)
+	d.mu.Unlock()
+}
+
+func (d *Dedup) run(ctx context.Context, key string, c *dedupCall, fn ParalyzableCtx) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.panik = r
+		}
+
+		d.mu.Lock()
+		close(c.done)
+		c.cancel()
+		if d.TTL <= 0 || c.err != nil || c.panik != nil {
+			d.forget(key, c)
+		} else {
+			d.clock().AfterFunc(d.TTL, func() {
+				d.mu.Lock()
+				d.forget(key, c)
+				d.mu.Unlock()
+			})
+		}
+		d.mu.Unlock()
+	}()
+	c.res, c.err = fn(ctx)
+}
+
+func (d *Dedup) wait(ctx context.Context, key string, c *dedupCall, shared bool) (interface{}, error, bool) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		d.mu.Lock()
+		c.waiters--
+		select {
+		case <-c.done:
+			// The result came in while giving up; use it after all.
+			d.mu.Unlock()
+		default:
+			if c.waiters == 0 {
+				c.cancel()
+				d.forget(key, c)
+			}
+			d.mu.Unlock()
+			return nil, ctx.Err(), shared
+		}
+	}
+
+	if c.panik != nil {
+		panic(c.panik)
+	}
+	return c.res, c.err, shared
+}
+
+// forget must be called with d.mu held.
+func (d *Dedup) forget(key string, c *dedupCall) {
+	if d.calls[key] == c {
+		delete(d.calls, key)
+	}
+}
+
+func (d *Dedup) clock() Clock {
+	if d.Clock == nil {
+		return systemClock{}
+	}
+	return d.Clock
+}
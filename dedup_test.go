@@ -0,0 +1,168 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// manualClock is a Clock whose time only moves and whose timers only fire
+// when told to.
+type manualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []func()
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = append(c.timers, f)
+	return time.NewTimer(time.Hour)
+}
+
+func (c *manualClock) fire() {
+	c.mu.Lock()
+	timers := c.timers
+	c.timers = nil
+	c.mu.Unlock()
+	for _, f := range timers {
+		f()
+	}
+}
+
+func TestDedupShared(t *testing.T) {
+	var d Dedup
+	var calls int32
+	release := make(chan struct{})
+	fn := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "user", nil
+	}
+
+	var wg sync.WaitGroup
+	shared := make([]bool, 5)
+	results := make([]interface{}, 5)
+	for i := range shared {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, shared[i] = d.Do(context.Background(), "user:1", fn)
+		}(i)
+	}
+	for {
+		d.mu.Lock()
+		c := d.calls["user:1"]
+		waiting := c != nil && c.waiters == 5
+		d.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls)
+	notShared := 0
+	for i := range shared {
+		assert.Equal(t, "user", results[i])
+		if !shared[i] {
+			notShared++
+		}
+	}
+	assert.Equal(t, 1, notShared)
+
+	d.Do(context.Background(), "user:1", fn)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestDedupTTL(t *testing.T) {
+	clock := &manualClock{}
+	d := Dedup{TTL: time.Minute, Clock: clock}
+	var calls int32
+	fn := func(context.Context) (interface{}, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	res, _, shared := d.Do(context.Background(), "k", fn)
+	assert.Equal(t, int32(1), res)
+	assert.False(t, shared)
+
+	res, _, shared = d.Do(context.Background(), "k", fn)
+	assert.Equal(t, int32(1), res)
+	assert.True(t, shared)
+
+	clock.fire()
+	res, _, shared = d.Do(context.Background(), "k", fn)
+	assert.Equal(t, int32(2), res)
+	assert.False(t, shared)
+
+	d.Forget("k")
+	res, _, _ = d.Do(context.Background(), "k", fn)
+	assert.Equal(t, int32(3), res)
+}
+
+func TestDedupErrorsNotCached(t *testing.T) {
+	d := Dedup{TTL: time.Minute, Clock: &manualClock{}}
+	_, err, _ := d.Do(context.Background(), "k", func(context.Context) (interface{}, error) {
+		return nil, someError
+	})
+	assert.Equal(t, someError, err)
+
+	res, err, shared := d.Do(context.Background(), "k", func(context.Context) (interface{}, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res)
+	assert.False(t, shared)
+}
+
+func TestDedupCancel(t *testing.T) {
+	var d Dedup
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err, _ := d.Do(ctx, "k", fn)
+		assert.Equal(t, context.Canceled, err)
+	}()
+
+	cancel()
+	<-done
+	<-canceled
+}
+
+func TestDedupWrap(t *testing.T) {
+	var d Dedup
+	results, errs := ParalyzeWithContext(context.Background(),
+		d.Wrap("a", func(context.Context) (interface{}, error) { return 1, nil }),
+		d.Wrap("b", func(context.Context) (interface{}, error) { return 2, nil }),
+	)
+	assert.Equal(t, []interface{}{1, 2}, results)
+	assert.Equal(t, []error{nil, nil}, errs)
+}
@@ -0,0 +1,70 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Estimates learns how long tasks take, by name, for the LongestFirst policy.
+// Give it to an Executor with WithEstimates to have it learn from the tasks
+// the Executor runs. The zero value is ready to use.
+type Estimates struct {
+	// Smoothing is how much each new duration moves a task's estimate
+	// towards it, between 0 and 1. It defaults to 0.3.
+	Smoothing float64
+
+	mu sync.Mutex
+	m  map[string]time.Duration
+}
+
+// Observe records that the task called name took d.
+func (e *Estimates) Observe(name string, d time.Duration) {
+	smoothing := e.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.3
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.m == nil {
+		e.m = make(map[string]time.Duration)
+	}
+	old, ok := e.m[name]
+	if !ok {
+		e.m[name] = d
+		return
+	}
+	e.m[name] = old + time.Duration(float64(d-old)*smoothing)
+}
+
+// Expected returns how long the task called name is expected to take, and
+// false if nothing has been learned about it yet.
+func (e *Estimates) Expected(name string) (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d, ok := e.m[name]
+	return d, ok
+}
+
+// WithEstimates makes an Executor record in est how long each of its tasks
+// with a name, or ParalyzeM key, takes. Tasks that are canceled aren't
+// recorded.
+func WithEstimates(est *Estimates) Option {
+	return func(e *Executor) {
+		e.wrappers = append(e.wrappers, func(b *batch, i int, fn ParalyzableCtx) ParalyzableCtx {
+			name := b.key(i)
+			if name == "" {
+				return fn
+			}
+			return func(ctx context.Context) (interface{}, error) {
+				start := b.clock.Now()
+				res, err := fn(ctx)
+				if !isCanceled(err) {
+					est.Observe(name, b.since(start))
+				}
+				return res, err
+			}
+		})
+	}
+}
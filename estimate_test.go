@@ -0,0 +1,74 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimates(t *testing.T) {
+	var est Estimates
+	_, ok := est.Expected("resize")
+	assert.False(t, ok)
+
+	est.Observe("resize", 100*time.Millisecond)
+	d, ok := est.Expected("resize")
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, d)
+
+	est.Observe("resize", 200*time.Millisecond)
+	d, _ = est.Expected("resize")
+	assert.Equal(t, 130*time.Millisecond, d)
+}
+
+func TestWithEstimates(t *testing.T) {
+	clock := &manualClock{}
+	est := &Estimates{}
+	e := NewExecutor(WithClock(clock), WithEstimates(est))
+
+	slow := func(context.Context) (interface{}, error) {
+		clock.advance(time.Second)
+		return nil, nil
+	}
+	canceled := func(context.Context) (interface{}, error) {
+		return nil, context.Canceled
+	}
+	e.Run(context.Background(), 1,
+		Task{Name: "slow", Fn: slow},
+		Task{Name: "canceled", Fn: canceled},
+		Task{Fn: slow},
+	)
+
+	d, ok := est.Expected("slow")
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+	_, ok = est.Expected("canceled")
+	assert.False(t, ok)
+}
+
+func TestLongestFirst(t *testing.T) {
+	est := &Estimates{}
+	est.Observe("learned", time.Minute)
+
+	p := LongestFirst(est)
+	submit := func(task Task, seq uint64) *waiter {
+		w := &waiter{task: &task, seq: seq}
+		p.(preparer).prepare(w)
+		return w
+	}
+	supplied := submit(Task{Cost: time.Hour, Name: "learned"}, 1)
+	learned := submit(Task{Name: "learned"}, 2)
+	unknown := submit(Task{Name: "unknown"}, 3)
+	none := submit(Task{}, 4)
+
+	assert.True(t, p.before(supplied, learned))
+	assert.True(t, p.before(learned, unknown))
+	assert.True(t, p.before(unknown, none))
+	assert.False(t, p.before(none, unknown))
+
+	// Learning more doesn't reorder tasks that are already waiting.
+	est.Observe("learned", 2*time.Hour)
+	assert.True(t, p.before(supplied, learned))
+}
@@ -0,0 +1,497 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// Executor runs batches the same way the package level functions do, but can
+// be configured with options that observe or change how each batch runs. The
+// package level functions use an Executor with no options. An Executor is
+// safe for concurrent use.
+type Executor struct {
+	label     string
+	ctx       context.Context
+	clock     Clock
+	observers []observer
+	wrappers  []wrapper
+	policy    Policy
+	limiter   *Limiter
+	caller    string
+	margin    time.Duration
+	state     *executorState
+}
+
+// Option configures an Executor.
+type Option func(*Executor)
+
+// NewExecutor returns an Executor configured with opts.
+func NewExecutor(opts ...Option) *Executor {
+	e := &Executor{
+		ctx:    context.Background(),
+		clock:  systemClock{},
+		policy: FIFO(),
+		state:  newExecutorState(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// With returns a copy of e with opts applied on top of its existing options.
+// It's cheap enough to call per batch, e.g. to attach a label. The copy is
+// shut down along with e.
+func (e *Executor) With(opts ...Option) *Executor {
+	c := *e
+	c.observers = append([]observer(nil), e.observers...)
+	c.wrappers = append([]wrapper(nil), e.wrappers...)
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
+}
+
+// WithLabel names the batches run by an Executor. The label shows up wherever
+// batches are reported.
+func WithLabel(label string) Option {
+	return func(e *Executor) { e.label = label }
+}
+
+// WithBaseContext sets the context that is used to report on batches that
+// aren't given one, i.e. everything except ParalyzeWithContext. Paralyzable
+// functions never see it, though Middleware is passed a context derived from
+// it. Canceling it doesn't stop those batches; only its deadline is heeded,
+// by ParalyzeWithTimeout.
+func WithBaseContext(ctx context.Context) Option {
+	return func(e *Executor) { e.ctx = ctx }
+}
+
+// base returns the context batches that aren't given one run with: the base
+// context's values, without its cancellation.
+func (e *Executor) base() context.Context {
+	return context.WithoutCancel(e.ctx)
+}
+
+var std = NewExecutor()
+
+// Task is a function to run along with what a Limiter needs to know to
+// schedule it.
+type Task struct {
+	Fn ParalyzableCtx
+
+	// Name identifies the task wherever it's reported, like a ParalyzeM
+	// key, and is what Estimates learns its durations by.
+	Name string
+
+	// Priority orders waiting tasks under the ByPriority policy. Higher
+	// priorities start first.
+	Priority int
+
+	// Partition names the bulkhead the task runs in, if any. See
+	// SetBulkhead.
+	Partition string
+
+	// Deadline is when the task must start by under the EarliestDeadline
+	// policy.
+	Deadline time.Time
+
+	// Cost is how long the task is expected to take, for the LongestFirst
+	// policy. If it's zero, the policy's Estimates are used instead.
+	Cost time.Duration
+
+	// Weight is how much of a Limiter's limit the task takes up while it
+	// runs, e.g. in proportion to the memory it needs. Zero or less counts
+	// as 1. A task weighing more than the limit runs on its own.
+	Weight int
+
+	// Input is what the task works on, recorded in its DeadLetter if it
+	// fails for good so that it can be replayed. It should be
+	// serializable to JSON for a DeadLetterFile.
+	Input interface{}
+}
+
+func (t *Task) weight() int64 {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return int64(t.Weight)
+}
+
+// WithPolicy sets the order in which waiting tasks start in batches with a
+// limit, i.e. ParalyzeLimit and Run. It doesn't affect Limiters given to
+// WithLimiter, which have their own policy.
+func WithPolicy(p Policy) Option {
+	return func(e *Executor) { e.policy = p }
+}
+
+// WithLimiter makes every task run by an Executor, in any kind of batch, wait
+// for room in l before it starts. Sharing l between Executors, or between
+// copies made with With, bounds the concurrency of all of them together.
+func WithLimiter(l *Limiter) Option {
+	return func(e *Executor) { e.limiter = l }
+}
+
+// WithCaller names who an Executor runs tasks for, e.g. a tenant or an
+// endpoint, so a shared Limiter can hold each caller to its Quota. Tasks a
+// Limiter turns away fail with ErrRejected.
+func WithCaller(name string) Option {
+	return func(e *Executor) { e.caller = name }
+}
+
+// Paralyze is the same as the package level Paralyze.
+func (e *Executor) Paralyze(funcs ...Paralyzable) ([]interface{}, []error) {
+	b := e.newBatch(e.ctx, kindParalyze, nil, len(funcs))
+	return e.run(b, tasksOf(funcs), runSpec{ctx: e.base(), repanic: true})
+}
+
+// ParalyzeM is the same as the package level ParalyzeM.
+func (e *Executor) ParalyzeM(m map[string]Paralyzable) map[string]ResErr {
+	var names []string
+	var fns []Paralyzable
+
+	for name, fn := range m {
+		names = append(names, name)
+		fns = append(fns, fn)
+	}
+
+	b := e.newBatch(e.ctx, kindParalyze, names, len(fns))
+	results, errs := e.run(b, tasksOf(fns), runSpec{ctx: e.base(), repanic: true})
+
+	res := make(map[string]ResErr)
+	for i := range results {
+		res[names[i]] = ResErr{
+			Res: results[i],
+			Err: errs[i],
+		}
+	}
+
+	return res
+}
+
+// ParalyzeWithTimeout is the same as the package level ParalyzeWithTimeout.
+// If the base context has a deadline, see WithBaseContext, the timeout is cut
+// short to end before it, like in ParalyzeWithBudget.
+func (e *Executor) ParalyzeWithTimeout(timeout time.Duration, funcs ...Paralyzable) ([]interface{}, []error) {
+	b := e.newBatch(e.ctx, kindTimeout, nil, len(funcs))
+	timeout, ok := e.budget(e.ctx, timeout)
+	if !ok {
+		return e.run(b, tasksOf(funcs), runSpec{ctx: e.base(), refuse: ErrTimedOut})
+	}
+	if timeout == 0 {
+		return e.run(b, tasksOf(funcs), runSpec{ctx: e.base(), repanic: true})
+	}
+
+	// Every task has been settled by the time the batch returns, so
+	// canceling ctx then only reaches the ones that were abandoned.
+	ctx, stop := context.WithCancelCause(e.base())
+	defer stop(ErrTimedOut)
+	cancel := make(chan struct{})
+	t := e.clock.AfterFunc(timeout, func() { close(cancel) })
+	defer t.Stop()
+
+	return e.run(b, tasksOf(funcs), runSpec{
+		ctx:      withDeadline(ctx, e.clock.Now().Add(timeout)),
+		cancel:   cancel,
+		abandon:  true,
+		canceled: ErrTimedOut,
+	})
+}
+
+// ParalyzeWithBudget is the same as the package level ParalyzeWithBudget.
+func (e *Executor) ParalyzeWithBudget(ctx context.Context, timeout time.Duration, funcs ...ParalyzableCtx) ([]interface{}, []error) {
+	b := e.newBatch(ctx, kindBudget, nil, len(funcs))
+	tasks := make([]Task, len(funcs))
+	for i, fn := range funcs {
+		tasks[i].Fn = fn
+	}
+
+	timeout, ok := e.budget(ctx, timeout)
+	if !ok {
+		return e.run(b, tasks, runSpec{ctx: ctx, refuse: ErrTimedOut})
+	}
+	if timeout == 0 {
+		return e.run(b, tasks, runSpec{ctx: ctx})
+	}
+
+	tctx, stop := withTimeout(ctx, e.clock, timeout)
+	defer stop()
+	return e.run(b, tasks, runSpec{ctx: tctx, abandon: true})
+}
+
+// budget returns how long a batch may take: timeout, or less if ctx's
+// deadline, less the safety margin, comes first. Zero means there's no limit.
+// It reports false if the deadline is too close to start at all.
+func (e *Executor) budget(ctx context.Context, timeout time.Duration) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout, true
+	}
+	left := deadline.Sub(e.clock.Now()) - e.margin
+	if left <= 0 {
+		return 0, false
+	}
+	if timeout == 0 || left < timeout {
+		return left, true
+	}
+	return timeout, true
+}
+
+// WithSafetyMargin makes batches that run with a deadline leave d of their
+// parent context's time unused, e.g. to send a response once they're done.
+func WithSafetyMargin(d time.Duration) Option {
+	return func(e *Executor) { e.margin = d }
+}
+
+// ParalyzeWithCancel is the same as the package level ParalyzeWithCancel.
+func (e *Executor) ParalyzeWithCancel(cancel <-chan struct{}, funcs ...Paralyzable) ([]interface{}, []error) {
+	b := e.newBatch(e.ctx, kindCancel, nil, len(funcs))
+
+	ctx, stop := context.WithCancelCause(e.base())
+	defer stop(ErrCanceled)
+
+	return e.run(b, tasksOf(funcs), runSpec{
+		ctx:      ctx,
+		cancel:   cancel,
+		abandon:  true,
+		canceled: ErrCanceled,
+	})
+}
+
+// ParalyzeWithContext is the same as the package level ParalyzeWithContext.
+// Tasks that are still waiting for a Limiter when ctx is done don't run, and
+// fail with ErrCanceled, or ErrTimedOut if ctx's deadline passed. If ctx was
+// canceled with a cause, see context.WithCancelCause, the error is a
+// CanceledError carrying it.
+func (e *Executor) ParalyzeWithContext(ctx context.Context, funcs ...ParalyzableCtx) ([]interface{}, []error) {
+	b := e.newBatch(ctx, kindContext, nil, len(funcs))
+	tasks := make([]Task, len(funcs))
+	for i, fn := range funcs {
+		tasks[i].Fn = fn
+	}
+	return e.run(b, tasks, runSpec{ctx: ctx})
+}
+
+// ParalyzeLimit is the same as the package level ParalyzeLimit. Tasks that
+// are waiting to start do so in the order set with WithPolicy.
+func (e *Executor) ParalyzeLimit(limit int, tasks ...Paralyzable) ([]interface{}, []error) {
+	b := e.newBatch(e.ctx, kindLimit, nil, len(tasks))
+	return e.run(b, tasksOf(tasks), runSpec{ctx: e.base(), repanic: true, local: e.local(limit)})
+}
+
+// PriorityAging is how long a task waits under ParalyzePriority to gain one
+// level of priority, see ByPriority.
+const PriorityAging = time.Second
+
+// ParalyzePriority is the same as the package level ParalyzePriority.
+func (e *Executor) ParalyzePriority(limit int, tasks ...Task) ([]interface{}, []error) {
+	return e.With(WithPolicy(ByPriority(PriorityAging))).Run(e.base(), limit, tasks...)
+}
+
+// ParalyzeWeighted is the same as the package level ParalyzeWeighted.
+func (e *Executor) ParalyzeWeighted(ctx context.Context, budget int, tasks ...Task) ([]interface{}, []error) {
+	return e.Run(ctx, budget, tasks...)
+}
+
+// Run runs tasks like ParalyzeWithContext, but at most limit at a time if
+// limit is positive, like ParalyzeLimit. Waiting tasks start in the order
+// set with WithPolicy, and don't start at all if ctx is done first. Panics
+// are passed on to the caller once the batch is done, like Paralyze.
+func (e *Executor) Run(ctx context.Context, limit int, tasks ...Task) ([]interface{}, []error) {
+	b := e.newBatch(ctx, kindRun, taskNames(tasks), len(tasks))
+	b.inputs = taskInputs(tasks)
+	return e.run(b, tasks, runSpec{ctx: ctx, repanic: true, local: e.local(limit)})
+}
+
+// local returns a Limiter of a batch's own if limit is positive.
+func (e *Executor) local(limit int) *Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return NewLimiter(limit, Schedule(e.policy), LimiterClock(e.clock))
+}
+
+// taskNames returns the names of tasks, or nil if none of them has one.
+func taskNames(tasks []Task) []string {
+	for _, t := range tasks {
+		if t.Name != "" {
+			names := make([]string, len(tasks))
+			for i := range tasks {
+				names[i] = tasks[i].Name
+			}
+			return names
+		}
+	}
+	return nil
+}
+
+// taskInputs returns the inputs of tasks, or nil if none of them has one.
+func taskInputs(tasks []Task) []interface{} {
+	for _, t := range tasks {
+		if t.Input != nil {
+			inputs := make([]interface{}, len(tasks))
+			for i := range tasks {
+				inputs[i] = tasks[i].Input
+			}
+			return inputs
+		}
+	}
+	return nil
+}
+
+func tasksOf(funcs []Paralyzable) []Task {
+	tasks := make([]Task, len(funcs))
+	for i, fn := range funcs {
+		tasks[i].Fn = ignoreCtx(fn)
+	}
+	return tasks
+}
+
+// batch kinds, named after the function that runs them.
+const (
+	kindParalyze = "paralyze"
+	kindTimeout  = "timeout"
+	kindCancel   = "cancel"
+	kindContext  = "context"
+	kindLimit    = "limit"
+	kindRun      = "run"
+	kindBudget   = "budget"
+)
+
+// observer is notified as a batch progresses. Calls for different tasks may
+// happen concurrently. taskReturned is called when a task's function returns,
+// and taskFinished when the batch settles on the task's result; they differ
+// for tasks that are abandoned by ParalyzeWithCancel or ParalyzeWithTimeout.
+type observer interface {
+	batchStarted(b *batch)
+	taskStarted(b *batch, i int)
+	taskReturned(b *batch, i int)
+	taskFinished(b *batch, i int, err error)
+	taskPanicked(b *batch, i int, r interface{}, stack []byte)
+	batchFinished(b *batch, errs []error)
+}
+
+// wrapper changes how task i of a batch is run. Wrappers are applied in the
+// order their options were given, so the first one is outermost.
+type wrapper func(b *batch, i int, fn ParalyzableCtx) ParalyzableCtx
+
+// batch is a single call to one of an Executor's methods.
+type batch struct {
+	id     uint64
+	ctx    context.Context
+	clock  Clock
+	label  string
+	kind   string
+	keys   []string
+	inputs []interface{}
+	size   int
+	start  time.Time
+	obs    []observer
+	wrap   []wrapper
+}
+
+var lastBatchID uint64
+
+func (e *Executor) newBatch(ctx context.Context, kind string, keys []string, size int) *batch {
+	return &batch{
+		id:    atomic.AddUint64(&lastBatchID, 1),
+		ctx:   ctx,
+		clock: e.clock,
+		label: e.label,
+		kind:  kind,
+		keys:  keys,
+		size:  size,
+		obs:   e.observers,
+		wrap:  e.wrappers,
+	}
+}
+
+// key returns the ParalyzeM key or Task Name of task i, if there is one.
+func (b *batch) key(i int) string {
+	if b.keys == nil {
+		return ""
+	}
+	return b.keys[i]
+}
+
+// input returns the Input of task i, if there is one.
+func (b *batch) input(i int) interface{} {
+	if b.inputs == nil {
+		return nil
+	}
+	return b.inputs[i]
+}
+
+// since is time.Since according to the batch's clock.
+func (b *batch) since(t time.Time) time.Duration {
+	return b.clock.Now().Sub(t)
+}
+
+func (b *batch) started() {
+	b.start = b.clock.Now()
+	for _, o := range b.obs {
+		o.batchStarted(b)
+	}
+}
+
+func (b *batch) finished(errs []error) {
+	for _, o := range b.obs {
+		o.batchFinished(b, errs)
+	}
+}
+
+func (b *batch) taskFinished(i int, err error) {
+	for _, o := range b.obs {
+		o.taskFinished(b, i, err)
+	}
+}
+
+// call runs task i. Panics are reported to the batch's observers before they
+// continue up the stack.
+func (b *batch) call(ctx context.Context, i int, fn ParalyzableCtx) (interface{}, error) {
+	for _, o := range b.obs {
+		o.taskStarted(b, i)
+	}
+	if len(b.obs) > 0 {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				for _, o := range b.obs {
+					o.taskPanicked(b, i, r, stack)
+				}
+				panic(r)
+			}
+		}()
+		defer func() {
+			for _, o := range b.obs {
+				o.taskReturned(b, i)
+			}
+		}()
+	}
+	for j := len(b.wrap) - 1; j >= 0; j-- {
+		fn = b.wrap[j](b, i, fn)
+	}
+	return fn(ctx)
+}
+
+// IgnoreContext adapts fn to be used where a ParalyzableCtx is needed, e.g. as
+// a Task's Fn.
+func IgnoreContext(fn Paralyzable) ParalyzableCtx {
+	return ignoreCtx(fn)
+}
+
+func ignoreCtx(fn Paralyzable) ParalyzableCtx {
+	return func(context.Context) (interface{}, error) { return fn() }
+}
+
+// isCanceled reports whether err means a task was cut short rather than that
+// it failed on its own.
+func isCanceled(err error) bool {
+	return errors.Is(err, ErrCanceled) ||
+		errors.Is(err, ErrTimedOut) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
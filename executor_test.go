@@ -0,0 +1,46 @@
+package paralyze
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutorWith(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewExecutor(WithLabel("base"))
+	e := base.With(WithLabel("child"), WithLogger(newTestLogger(&buf), nil))
+
+	base.Paralyze(fastFn)
+	assert.Equal(t, 0, buf.Len())
+
+	results, errs := e.Paralyze(fastFn, errFn)
+	assert.Equal(t, []interface{}{55, nil}, results)
+	assert.Equal(t, []error{nil, someError}, errs)
+	assert.Equal(t, "child", findRecord(logRecords(t, &buf), "paralyze batch started")["label"])
+}
+
+func TestExecutorBaseContextCanceled(t *testing.T) {
+	// The base context is only used to report on batches, so canceling it
+	// doesn't stop them.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e := NewExecutor(WithBaseContext(ctx))
+	ok := []error{nil, nil, nil}
+
+	_, errs := e.ParalyzeLimit(1, fastFn, fastFn, fastFn)
+	assert.Equal(t, ok, errs)
+	_, errs = e.With(WithLimiter(NewLimiter(1))).Paralyze(fastFn, fastFn, fastFn)
+	assert.Equal(t, ok, errs)
+	_, errs = e.ParalyzeWithCancel(nil, fastFn, fastFn, fastFn)
+	assert.Equal(t, ok, errs)
+	_, errs = e.ParalyzeWithTimeout(time.Second, fastFn, fastFn, fastFn)
+	assert.Equal(t, ok, errs)
+
+	task := Task{Fn: func(ctx context.Context) (interface{}, error) { return nil, ctx.Err() }}
+	_, errs = e.ParalyzePriority(1, task, task, task)
+	assert.Equal(t, ok, errs)
+}
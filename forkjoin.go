@@ -0,0 +1,302 @@
+package paralyze
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ForkFunc is a task run by a Pool. It can split itself up by forking
+// subtasks on w and joining them.
+type ForkFunc func(w *Worker) (interface{}, error)
+
+// Pool runs recursive, divide and conquer tasks on a fixed number of workers.
+// Each worker keeps the tasks it forks in a deque of its own and works
+// through it newest first, so most forks are joined by the same worker
+// without any hand-off. Idle workers steal the oldest, and so usually the
+// largest, tasks from the others. This makes forking far cheaper than
+// starting a goroutine, and keeps the number of goroutines fixed however
+// deep the recursion goes.
+type Pool struct {
+	workers []*Worker
+	inject  deque
+	wg      sync.WaitGroup
+
+	// queued counts the tasks in the deques and the inject queue, including
+	// ones that have already been joined and will be skipped.
+	queued int64
+	idle   int64
+	mu     sync.Mutex
+	wake   *sync.Cond
+	closed bool
+}
+
+// Worker is one of a Pool's workers, as passed to the tasks it runs.
+type Worker struct {
+	pool *Pool
+	dq   deque
+	ctx  context.Context
+	rand uint32
+}
+
+// Future is the pending result of a task run by a Pool or Lanes.
+type Future struct {
+	fn    ForkFunc
+	ctx   context.Context
+	state int32
+	done  chan struct{}
+	res   interface{}
+	err   error
+	panik interface{}
+}
+
+const (
+	futurePending int32 = iota
+	futureClaimed
+)
+
+// NewPool starts a Pool with n workers, or GOMAXPROCS if n isn't positive.
+// It must be closed when it's no longer needed.
+func NewPool(n int) *Pool {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	p := &Pool{}
+	p.wake = sync.NewCond(&p.mu)
+	for i := 0; i < n; i++ {
+		w := &Worker{pool: p, rand: uint32(i)*2654435761 + 1}
+		p.workers = append(p.workers, w)
+	}
+	p.wg.Add(n)
+	for _, w := range p.workers {
+		go w.loop()
+	}
+	return p
+}
+
+// Invoke runs fn on the pool and waits for it, along with everything it
+// forks and joins. Tasks that haven't started once ctx is done are skipped,
+// and joining them returns ErrCanceled or ErrTimedOut. A panic in fn, or in
+// a subtask it joins, is passed on to the caller. Invoking a closed Pool
+// fails with ErrShutdown.
+func (p *Pool) Invoke(ctx context.Context, fn ForkFunc) (interface{}, error) {
+	// The task is queued under p.mu, so workers can't see the pool as
+	// closed and idle and exit while it's on its way in.
+	f := newFuture(ctx, fn)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrShutdown
+	}
+	p.inject.push(f)
+	atomic.AddInt64(&p.queued, 1)
+	p.wake.Signal()
+	p.mu.Unlock()
+	<-f.done
+	return f.result()
+}
+
+// Close stops the pool's workers once the tasks already invoked are done.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.wake.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Context returns the context of the task w is running.
+func (w *Worker) Context() context.Context {
+	return w.ctx
+}
+
+// Fork queues fn to run on the pool, with the same context as the task that
+// forks it. It must only be called from a task running on w.
+func (w *Worker) Fork(fn ForkFunc) *Future {
+	f := newFuture(w.ctx, fn)
+	w.dq.push(f)
+	w.pool.added()
+	return f
+}
+
+// Join waits for f and returns its result. If f hasn't started yet, w runs it
+// right away; otherwise w runs other tasks while it waits. A panic in f is
+// passed on. It must only be called from a task running on w.
+func (w *Worker) Join(f *Future) (interface{}, error) {
+	if atomic.LoadInt32(&f.state) == futurePending {
+		w.run(f)
+	}
+	for {
+		select {
+		case <-f.done:
+			return f.result()
+		default:
+		}
+		if g := w.find(); g != nil {
+			w.run(g)
+			continue
+		}
+		<-f.done
+		return f.result()
+	}
+}
+
+// Done returns a channel that's closed once f has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for f to finish and returns its result, passing on its panic if
+// it had one. Within a Pool, use Worker.Join instead, which helps run other
+// tasks while it waits.
+func (f *Future) Wait() (interface{}, error) {
+	<-f.done
+	return f.result()
+}
+
+func newFuture(ctx context.Context, fn ForkFunc) *Future {
+	return &Future{fn: fn, ctx: ctx, done: make(chan struct{})}
+}
+
+func (f *Future) result() (interface{}, error) {
+	if f.panik != nil {
+		panic(f.panik)
+	}
+	return f.res, f.err
+}
+
+// added wakes a worker, if one is idle, to take a task that was just queued.
+func (p *Pool) added() {
+	atomic.AddInt64(&p.queued, 1)
+	if atomic.LoadInt64(&p.idle) > 0 {
+		p.mu.Lock()
+		p.wake.Signal()
+		p.mu.Unlock()
+	}
+}
+
+func (w *Worker) loop() {
+	defer w.pool.wg.Done()
+	for {
+		if f := w.find(); f != nil {
+			w.run(f)
+			continue
+		}
+		if !w.pool.park() {
+			return
+		}
+	}
+}
+
+// park waits until there may be tasks to take. It reports false once the
+// pool is closed and there's nothing left to do.
+func (p *Pool) park() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	atomic.AddInt64(&p.idle, 1)
+	defer atomic.AddInt64(&p.idle, -1)
+	for atomic.LoadInt64(&p.queued) == 0 {
+		if p.closed {
+			return false
+		}
+		p.wake.Wait()
+	}
+	return true
+}
+
+// find takes the next task for w: the newest of its own, or one from the
+// inject queue, or the oldest of another worker's.
+func (w *Worker) find() *Future {
+	f := w.take()
+	if f != nil {
+		atomic.AddInt64(&w.pool.queued, -1)
+	}
+	return f
+}
+
+func (w *Worker) take() *Future {
+	if f := w.dq.pop(); f != nil {
+		return f
+	}
+	if f := w.pool.inject.steal(); f != nil {
+		return f
+	}
+	n := len(w.pool.workers)
+	w.rand ^= w.rand << 13
+	w.rand ^= w.rand >> 17
+	w.rand ^= w.rand << 5
+	start := int(w.rand % uint32(n))
+	for i := 0; i < n; i++ {
+		v := w.pool.workers[(start+i)%n]
+		if v == w {
+			continue
+		}
+		if f := v.dq.steal(); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// run runs f on w, unless it has been claimed already: a task joined before
+// it was taken is left in its deque, and skipped once it's taken.
+func (w *Worker) run(f *Future) {
+	if !atomic.CompareAndSwapInt32(&f.state, futurePending, futureClaimed) {
+		return
+	}
+
+	outer := w.ctx
+	w.ctx = f.ctx
+	defer func() {
+		w.ctx = outer
+		if r := recover(); r != nil {
+			f.panik = r
+		}
+		close(f.done)
+	}()
+
+	if f.ctx.Err() != nil {
+		f.err = canceledErr(f.ctx)
+		return
+	}
+	f.res, f.err = f.fn(w)
+}
+
+// deque is a worker's queue of forked tasks. The worker pushes and pops at
+// the back; thieves steal from the front.
+type deque struct {
+	mu    sync.Mutex
+	items []*Future
+}
+
+func (d *deque) push(f *Future) {
+	d.mu.Lock()
+	d.items = append(d.items, f)
+	d.mu.Unlock()
+}
+
+func (d *deque) pop() *Future {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.items)
+	if n == 0 {
+		return nil
+	}
+	f := d.items[n-1]
+	d.items[n-1] = nil
+	d.items = d.items[:n-1]
+	return f
+}
+
+func (d *deque) steal() *Future {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.items) == 0 {
+		return nil
+	}
+	f := d.items[0]
+	d.items[0] = nil
+	d.items = d.items[1:]
+	return f
+}
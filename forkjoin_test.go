@@ -0,0 +1,178 @@
+package paralyze
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sum adds up xs by splitting it in half until the halves are small.
+func sum(xs []int) ForkFunc {
+	return func(w *Worker) (interface{}, error) {
+		if len(xs) <= 4 {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total, nil
+		}
+		left := w.Fork(sum(xs[:len(xs)/2]))
+		r, err := sum(xs[len(xs)/2:])(w)
+		if err != nil {
+			return nil, err
+		}
+		l, err := w.Join(left)
+		if err != nil {
+			return nil, err
+		}
+		return l.(int) + r.(int), nil
+	}
+}
+
+func mergeSort(xs []int) ForkFunc {
+	return func(w *Worker) (interface{}, error) {
+		if len(xs) <= 1 {
+			return xs, nil
+		}
+		left := w.Fork(mergeSort(xs[:len(xs)/2]))
+		right := w.Fork(mergeSort(xs[len(xs)/2:]))
+		r, _ := w.Join(right)
+		l, _ := w.Join(left)
+		a, b := l.([]int), r.([]int)
+		out := make([]int, 0, len(xs))
+		for len(a) > 0 && len(b) > 0 {
+			if a[0] <= b[0] {
+				out, a = append(out, a[0]), a[1:]
+			} else {
+				out, b = append(out, b[0]), b[1:]
+			}
+		}
+		return append(append(out, a...), b...), nil
+	}
+}
+
+func TestPoolSum(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+
+	xs := make([]int, 10000)
+	for i := range xs {
+		xs[i] = i
+	}
+	total, err := p.Invoke(context.Background(), sum(xs))
+	assert.NoError(t, err)
+	assert.Equal(t, 10000*9999/2, total)
+}
+
+func TestPoolMergeSort(t *testing.T) {
+	p := NewPool(0)
+	defer p.Close()
+
+	xs := make([]int, 1000)
+	for i := range xs {
+		xs[i] = (i * 7919) % 1000
+	}
+	sorted, err := p.Invoke(context.Background(), mergeSort(xs))
+	assert.NoError(t, err)
+	assert.True(t, sort.IntsAreSorted(sorted.([]int)))
+	assert.Len(t, sorted, 1000)
+}
+
+func TestPoolSteals(t *testing.T) {
+	const n = 4
+	p := NewPool(n)
+	defer p.Close()
+
+	// Every subtask waits for all the others to start, so they only finish
+	// if the other workers steal them.
+	var started sync.WaitGroup
+	started.Add(n)
+	_, err := p.Invoke(context.Background(), func(w *Worker) (interface{}, error) {
+		var fs []*Future
+		for i := 0; i < n; i++ {
+			fs = append(fs, w.Fork(func(*Worker) (interface{}, error) {
+				started.Done()
+				started.Wait()
+				return nil, nil
+			}))
+		}
+		for _, f := range fs {
+			w.Join(f)
+		}
+		return nil, nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestPoolError(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	_, err := p.Invoke(context.Background(), func(w *Worker) (interface{}, error) {
+		f := w.Fork(func(*Worker) (interface{}, error) { return nil, someError })
+		return w.Join(f)
+	})
+	assert.Equal(t, someError, err)
+}
+
+func TestPoolCanceled(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := p.Invoke(ctx, func(w *Worker) (interface{}, error) {
+		assert.Equal(t, ctx, w.Context())
+		cancel()
+		f := w.Fork(func(*Worker) (interface{}, error) {
+			t.Error("canceled task ran")
+			return nil, nil
+		})
+		return w.Join(f)
+	})
+	assert.Equal(t, ErrCanceled, err)
+}
+
+func TestPoolPanic(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		p.Invoke(context.Background(), func(w *Worker) (interface{}, error) {
+			f := w.Fork(func(*Worker) (interface{}, error) { panic("boom") })
+			return w.Join(f)
+		})
+	})
+}
+
+func TestPoolClosed(t *testing.T) {
+	p := NewPool(2)
+	p.Close()
+
+	_, err := p.Invoke(context.Background(), sum([]int{1, 2}))
+	assert.Equal(t, ErrShutdown, err)
+}
+
+func TestPoolInvokeWhileClosing(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p := NewPool(2)
+		done := make(chan error)
+		go func() {
+			_, err := p.Invoke(context.Background(), sum([]int{1, 2}))
+			done <- err
+		}()
+		p.Close()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				assert.Equal(t, ErrShutdown, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Invoke never returned")
+		}
+	}
+}
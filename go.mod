@@ -1,6 +1,11 @@
 module github.com/i/paralyze
 
+go 1.21
+
+require github.com/stretchr/testify v1.3.0
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/stretchr/testify v1.3.0
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
 )
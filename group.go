@@ -0,0 +1,158 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrorPolicy decides what a Group does with the errors of its tasks.
+type ErrorPolicy int
+
+const (
+	// FailFast cancels the group on the first error, which Wait returns.
+	FailFast ErrorPolicy = iota
+
+	// CollectAll lets every task run, and Wait returns all of their errors
+	// joined with errors.Join.
+	CollectAll
+
+	// IgnoreErrors drops errors. They neither cancel the group nor reach its
+	// parent, and Wait returns nil.
+	IgnoreErrors
+)
+
+// Group runs tasks that can be added to it over time, and waits for all of
+// them. Groups form a tree: canceling a group cancels its descendants, a
+// group's Wait waits for its descendants' tasks too, and the errors a child
+// group would return from Wait are passed on to its parent as they happen,
+// to be handled by the parent's policy.
+//
+// Like Paralyze, a task's panic is passed on to the caller of Wait, here once
+// every task is done. It's passed on by the Wait of every ancestor too, so
+// they are all canceled when one happens.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	policy ErrorPolicy
+	parent *Group
+
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	tasks int
+	errs  []error
+	panik interface{}
+}
+
+// NewGroup returns a Group whose tasks are passed a context derived from ctx.
+// When a FailFast group is canceled because a task failed, context.Cause
+// returns a SiblingFailedError for the context, in the group and its
+// descendants.
+func NewGroup(ctx context.Context, policy ErrorPolicy) *Group {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{ctx: ctx, cancel: cancel, policy: policy}
+}
+
+// Child returns a new group under g. It's canceled when g is.
+func (g *Group) Child(policy ErrorPolicy) *Group {
+	c := NewGroup(g.ctx, policy)
+	c.parent = g
+	return c
+}
+
+// Context returns the context passed to g's tasks. It's done once g is
+// canceled or Wait returns.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Cancel cancels g and all of its descendants.
+func (g *Group) Cancel() {
+	g.cancel(nil)
+}
+
+// Go runs fn in g. It must not be called once Wait has returned for g or any
+// of its ancestors.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	for a := g; a != nil; a = a.parent {
+		a.wg.Add(1)
+	}
+	g.mu.Lock()
+	i := g.tasks
+	g.tasks++
+	g.mu.Unlock()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(r, &SiblingFailedError{Task: i, Err: fmt.Errorf("panic: %v", r)})
+			}
+			for a := g; a != nil; a = a.parent {
+				a.wg.Done()
+			}
+		}()
+		if err := fn(g.ctx); err != nil {
+			g.report(err, &SiblingFailedError{Task: i, Err: err})
+		}
+	}()
+}
+
+// Wait waits for the tasks of g and its descendants to finish, then returns
+// according to g's policy.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.panik != nil {
+		panic(g.panik)
+	}
+	switch g.policy {
+	case FailFast:
+		if len(g.errs) > 0 {
+			return g.errs[0]
+		}
+	case CollectAll:
+		return errors.Join(g.errs...)
+	}
+	return nil
+}
+
+// report handles an error in g, and passes it up if g keeps it. cause is
+// what a FailFast group is canceled with.
+func (g *Group) report(err error, cause error) {
+	if g.policy == IgnoreErrors {
+		return
+	}
+
+	g.mu.Lock()
+	first := len(g.errs) == 0
+	if g.policy == CollectAll || first {
+		g.errs = append(g.errs, err)
+	}
+	g.mu.Unlock()
+
+	if g.policy == FailFast {
+		if !first {
+			return
+		}
+		g.cancel(cause)
+	}
+	if g.parent != nil {
+		g.parent.report(err, cause)
+	}
+}
+
+// fail records a task's panic in g and its ancestors, and cancels them.
+func (g *Group) fail(r interface{}, cause error) {
+	for a := g; a != nil; a = a.parent {
+		a.mu.Lock()
+		if a.panik == nil {
+			a.panik = r
+		}
+		a.mu.Unlock()
+		a.cancel(cause)
+	}
+}
@@ -0,0 +1,96 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForDone(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestGroupFailFast(t *testing.T) {
+	g := NewGroup(context.Background(), FailFast)
+	g.Go(waitForDone)
+	g.Go(waitForDone)
+	g.Go(func(context.Context) error { return someError })
+
+	assert.Equal(t, someError, g.Wait())
+	assert.Equal(t, context.Canceled, g.Context().Err())
+}
+
+func TestGroupCollectAll(t *testing.T) {
+	other := errors.New("other error")
+	g := NewGroup(context.Background(), CollectAll)
+
+	var ran int64
+	for _, err := range []error{someError, nil, other} {
+		err := err
+		g.Go(func(context.Context) error {
+			atomic.AddInt64(&ran, 1)
+			return err
+		})
+	}
+
+	err := g.Wait()
+	assert.Equal(t, int64(3), ran)
+	assert.True(t, errors.Is(err, someError))
+	assert.True(t, errors.Is(err, other))
+}
+
+func TestGroupIgnoreErrors(t *testing.T) {
+	g := NewGroup(context.Background(), IgnoreErrors)
+	g.Go(func(context.Context) error { return someError })
+	g.Go(func(ctx context.Context) error { return ctx.Err() })
+	assert.NoError(t, g.Wait())
+}
+
+func TestGroupChildren(t *testing.T) {
+	root := NewGroup(context.Background(), FailFast)
+	ignored := root.Child(IgnoreErrors)
+	failing := root.Child(CollectAll)
+	grandchild := failing.Child(FailFast)
+
+	returned := make(chan struct{})
+	ignored.Go(func(context.Context) error {
+		defer close(returned)
+		return errors.New("ignored")
+	})
+	grandchild.Go(waitForDone)
+
+	// Once the ignored group has seen its error, nothing above it has.
+	<-returned
+	assert.NoError(t, ignored.Wait())
+	assert.NoError(t, grandchild.Context().Err())
+
+	// The error goes up through the CollectAll child, and the root fails
+	// fast, canceling every descendant.
+	failing.Go(func(context.Context) error { return someError })
+	assert.Equal(t, someError, root.Wait())
+	assert.Equal(t, context.Canceled, grandchild.Context().Err())
+	assert.Equal(t, context.Canceled, grandchild.Wait())
+}
+
+func TestGroupCancel(t *testing.T) {
+	root := NewGroup(context.Background(), CollectAll)
+	child := root.Child(CollectAll)
+	child.Go(waitForDone)
+
+	root.Cancel()
+	assert.True(t, errors.Is(root.Wait(), context.Canceled))
+	assert.True(t, errors.Is(child.Wait(), context.Canceled))
+}
+
+func TestGroupPanic(t *testing.T) {
+	root := NewGroup(context.Background(), FailFast)
+	child := root.Child(FailFast)
+	child.Go(func(context.Context) error { panic("whoops") })
+	root.Go(waitForDone)
+
+	assert.PanicsWithValue(t, "whoops", func() { root.Wait() })
+}
@@ -0,0 +1,99 @@
+package paralyze
+
+// BatchInfo describes a batch run by an Executor.
+type BatchInfo struct {
+	// ID identifies the batch among all others run in this process.
+	ID    uint64
+	Label string
+
+	// Kind is the kind of batch, named after the function that runs it:
+	// "paralyze", "timeout", "cancel", "context", "limit", "run" or
+	// "budget".
+	Kind string
+	Size int
+}
+
+// TaskInfo describes a task within a batch.
+type TaskInfo struct {
+	Batch BatchInfo
+	Index int
+
+	// Key is the task's key in the map given to ParalyzeM, or its Task
+	// Name, if any.
+	Key string
+}
+
+// Middleware wraps each task an Executor runs. The function it returns is
+// called in place of fn, with a context that is canceled once the batch no
+// longer needs the task's result. Tasks that don't take a context never see
+// it.
+type Middleware func(info TaskInfo, fn ParalyzableCtx) ParalyzableCtx
+
+// WithMiddleware makes an Executor wrap every task with mw. The first
+// middleware given is the outermost.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(e *Executor) {
+		for _, m := range mw {
+			m := m
+			e.wrappers = append(e.wrappers, func(b *batch, i int, fn ParalyzableCtx) ParalyzableCtx {
+				return m(b.taskInfo(i), fn)
+			})
+		}
+	}
+}
+
+// Hooks are called as an Executor runs a batch. Any of them may be nil. Hooks
+// for different tasks can be called concurrently and should return quickly.
+type Hooks struct {
+	BatchStarted func(BatchInfo)
+	TaskStarted  func(TaskInfo)
+
+	// TaskFinished is called once the batch has settled on a task's result.
+	// For tasks abandoned by ParalyzeWithCancel or ParalyzeWithTimeout that's
+	// when they are canceled, not when they return.
+	TaskFinished  func(TaskInfo, error)
+	BatchFinished func(BatchInfo, []error)
+}
+
+// WithHooks makes an Executor call h as its batches run.
+func WithHooks(h Hooks) Option {
+	return func(e *Executor) { e.observers = append(e.observers, hooksObserver(h)) }
+}
+
+type hooksObserver Hooks
+
+func (h hooksObserver) batchStarted(b *batch) {
+	if h.BatchStarted != nil {
+		h.BatchStarted(b.info())
+	}
+}
+
+func (h hooksObserver) taskStarted(b *batch, i int) {
+	if h.TaskStarted != nil {
+		h.TaskStarted(b.taskInfo(i))
+	}
+}
+
+func (h hooksObserver) taskReturned(b *batch, i int) {}
+
+func (h hooksObserver) taskFinished(b *batch, i int, err error) {
+	if h.TaskFinished != nil {
+		h.TaskFinished(b.taskInfo(i), err)
+	}
+}
+
+func (h hooksObserver) taskPanicked(b *batch, i int, r interface{}, stack []byte) {}
+
+func (h hooksObserver) batchFinished(b *batch, errs []error) {
+	if h.BatchFinished != nil {
+		h.BatchFinished(b.info(), errs)
+	}
+}
+
+func (b *batch) info() BatchInfo {
+	return BatchInfo{ID: b.id, Label: b.label, Kind: b.kind, Size: b.size}
+}
+
+func (b *batch) taskInfo(i int) TaskInfo {
+	return TaskInfo{Batch: b.info(), Index: i, Key: b.key(i)}
+}
@@ -0,0 +1,59 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(info TaskInfo, fn ParalyzableCtx) ParalyzableCtx {
+			return func(ctx context.Context) (interface{}, error) {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				res, err := fn(ctx)
+				return []interface{}{info.Key, res}, err
+			}
+		}
+	}
+
+	e := NewExecutor(WithMiddleware(mw("outer"), mw("inner")))
+	results := e.ParalyzeM(map[string]Paralyzable{"five": fastFn})
+
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+	assert.Equal(t, []interface{}{"five", []interface{}{"five", 55}}, results["five"].Res)
+}
+
+func TestWithHooks(t *testing.T) {
+	var mu sync.Mutex
+	var started, finished []int
+	var batch BatchInfo
+	var batchErrs []error
+
+	e := NewExecutor(WithLabel("hooked"), WithHooks(Hooks{
+		BatchStarted: func(b BatchInfo) { batch = b },
+		TaskStarted: func(info TaskInfo) {
+			mu.Lock()
+			started = append(started, info.Index)
+			mu.Unlock()
+		},
+		TaskFinished: func(info TaskInfo, err error) {
+			mu.Lock()
+			finished = append(finished, info.Index)
+			mu.Unlock()
+		},
+		BatchFinished: func(b BatchInfo, errs []error) { batchErrs = errs },
+	}))
+	e.ParalyzeLimit(1, fastFn, errFn)
+
+	assert.Equal(t, BatchInfo{ID: batch.ID, Label: "hooked", Kind: "limit", Size: 2}, batch)
+	assert.Equal(t, []int{0, 1}, started)
+	assert.Equal(t, []int{0, 1}, finished)
+	assert.Equal(t, []error{nil, someError}, batchErrs)
+}
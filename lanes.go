@@ -0,0 +1,154 @@
+package paralyze
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// Lanes runs tasks in order per key and in parallel across keys. Each key is
+// hashed to one of a fixed number of lanes, and the tasks in a lane run one at
+// a time, in the order they were submitted. Keys that share a lane wait on
+// each other, so more lanes means less of that, at the cost of a goroutine
+// each.
+//
+// A lane holds a bounded number of waiting tasks. Once it's full, Submit
+// blocks and TrySubmit fails, which pushes back on producers that outpace
+// the lane instead of letting its queue grow without bound.
+type Lanes struct {
+	lanes []chan *laneTask
+	depth int
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// LanesOption configures Lanes.
+type LanesOption func(*Lanes)
+
+// LaneDepth sets how many tasks can wait in each lane before Submit blocks.
+// It's 64 by default.
+func LaneDepth(n int) LanesOption {
+	return func(l *Lanes) { l.depth = n }
+}
+
+type laneTask struct {
+	ctx context.Context
+	fn  ParalyzableCtx
+	f   *Future
+}
+
+// NewLanes starts n lanes, or GOMAXPROCS if n isn't positive. They must be
+// closed when they're no longer needed.
+func NewLanes(n int, opts ...LanesOption) *Lanes {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	l := &Lanes{depth: 64}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.depth < 0 {
+		l.depth = 0
+	}
+	l.wg.Add(n)
+	for i := 0; i < n; i++ {
+		lane := make(chan *laneTask, l.depth)
+		l.lanes = append(l.lanes, lane)
+		go l.drain(lane)
+	}
+	return l
+}
+
+// Submit queues fn in key's lane, waiting for room if the lane is full. It
+// fails with the error ctx was canceled with if ctx is done before there's
+// room, or ErrShutdown if the Lanes are closed. fn is passed ctx, and is
+// skipped if ctx is done by the time its turn comes.
+func (l *Lanes) Submit(ctx context.Context, key string, fn ParalyzableCtx) (*Future, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return nil, ErrShutdown
+	}
+	t := newLaneTask(ctx, fn)
+	select {
+	case l.lane(key) <- t:
+		return t.f, nil
+	case <-ctx.Done():
+		return nil, canceledErr(ctx)
+	}
+}
+
+// TrySubmit is like Submit, but fails with ErrRejected rather than wait if
+// key's lane is full.
+func (l *Lanes) TrySubmit(ctx context.Context, key string, fn ParalyzableCtx) (*Future, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return nil, ErrShutdown
+	}
+	t := newLaneTask(ctx, fn)
+	select {
+	case l.lane(key) <- t:
+		return t.f, nil
+	default:
+		return nil, ErrRejected
+	}
+}
+
+// Queued returns the number of tasks waiting in key's lane, which includes
+// those of any other keys that share it.
+func (l *Lanes) Queued(key string) int {
+	return len(l.lane(key))
+}
+
+// Close stops the Lanes from taking new tasks, then waits for the ones
+// already submitted to finish.
+func (l *Lanes) Close() {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		for _, lane := range l.lanes {
+			close(lane)
+		}
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+func (l *Lanes) lane(key string) chan *laneTask {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return l.lanes[h.Sum32()%uint32(len(l.lanes))]
+}
+
+func (l *Lanes) drain(lane chan *laneTask) {
+	defer l.wg.Done()
+	for t := range lane {
+		t.run()
+	}
+}
+
+// newLaneTask returns a task whose Future is claimed by its lane from the
+// start, so a Pool worker joining it only waits for it.
+func newLaneTask(ctx context.Context, fn ParalyzableCtx) *laneTask {
+	f := newFuture(ctx, nil)
+	f.state = futureClaimed
+	return &laneTask{ctx: ctx, fn: fn, f: f}
+}
+
+func (t *laneTask) run() {
+	defer func() {
+		if r := recover(); r != nil {
+			t.f.panik = r
+		}
+		close(t.f.done)
+	}()
+	if t.ctx.Err() != nil {
+		t.f.err = canceledErr(t.ctx)
+		return
+	}
+	t.f.res, t.f.err = t.fn(t.ctx)
+}
@@ -0,0 +1,176 @@
+package paralyze
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanesOrderPerKey(t *testing.T) {
+	l := NewLanes(4)
+	defer l.Close()
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var fs []*Future
+	for i := 0; i < 100; i++ {
+		i := i
+		key := fmt.Sprintf("key-%d", i%7)
+		f, err := l.Submit(context.Background(), key, func(context.Context) (interface{}, error) {
+			mu.Lock()
+			seen[key] = append(seen[key], i)
+			mu.Unlock()
+			return i, nil
+		})
+		assert.NoError(t, err)
+		fs = append(fs, f)
+	}
+	for i, f := range fs {
+		res, err := f.Wait()
+		assert.NoError(t, err)
+		assert.Equal(t, i, res)
+	}
+
+	assert.Len(t, seen, 7)
+	for key, order := range seen {
+		for j := 1; j < len(order); j++ {
+			assert.True(t, order[j-1] < order[j], "%s ran out of order: %v", key, order)
+		}
+	}
+}
+
+// keysInDifferentLanes returns two keys that hash to different lanes of l.
+func keysInDifferentLanes(l *Lanes) (string, string) {
+	for i := 1; ; i++ {
+		other := fmt.Sprintf("key-%d", i)
+		if l.lane(other) != l.lane("key-0") {
+			return "key-0", other
+		}
+	}
+}
+
+func TestLanesRunConcurrently(t *testing.T) {
+	l := NewLanes(2)
+	defer l.Close()
+	a, b := keysInDifferentLanes(l)
+
+	// Each task waits for the other, so they only finish if their lanes run
+	// at the same time.
+	var started sync.WaitGroup
+	started.Add(2)
+	task := func(context.Context) (interface{}, error) {
+		started.Done()
+		started.Wait()
+		return nil, nil
+	}
+	fa, _ := l.Submit(context.Background(), a, task)
+	fb, _ := l.Submit(context.Background(), b, task)
+	<-fa.Done()
+	<-fb.Done()
+}
+
+func TestLanesBackpressure(t *testing.T) {
+	l := NewLanes(1, LaneDepth(1))
+	defer l.Close()
+
+	block := make(chan struct{})
+	running := make(chan struct{})
+	first, err := l.Submit(context.Background(), "a", func(context.Context) (interface{}, error) {
+		close(running)
+		<-block
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	<-running
+
+	queued, err := l.TrySubmit(context.Background(), "a", IgnoreContext(fastFn))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, l.Queued("a"))
+
+	_, err = l.TrySubmit(context.Background(), "b", IgnoreContext(fastFn))
+	assert.Equal(t, ErrRejected, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.Submit(ctx, "a", IgnoreContext(fastFn))
+	assert.Equal(t, ErrCanceled, err)
+
+	close(block)
+	first.Wait()
+	res, err := queued.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, 55, res)
+}
+
+func TestLanesSkipCanceled(t *testing.T) {
+	l := NewLanes(1)
+	defer l.Close()
+
+	block := make(chan struct{})
+	l.Submit(context.Background(), "a", func(context.Context) (interface{}, error) {
+		<-block
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := l.Submit(ctx, "a", func(context.Context) (interface{}, error) {
+		t.Error("canceled task ran")
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	cancel()
+	close(block)
+
+	_, err = f.Wait()
+	assert.Equal(t, ErrCanceled, err)
+}
+
+func TestLanesPanic(t *testing.T) {
+	l := NewLanes(1)
+	defer l.Close()
+
+	f, _ := l.Submit(context.Background(), "a", func(context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	assert.PanicsWithValue(t, "boom", func() { f.Wait() })
+
+	// The lane keeps going.
+	f, _ = l.Submit(context.Background(), "a", IgnoreContext(fastFn))
+	res, _ := f.Wait()
+	assert.Equal(t, 55, res)
+}
+
+func TestLanesClosed(t *testing.T) {
+	l := NewLanes(2)
+	f, _ := l.Submit(context.Background(), "a", IgnoreContext(fastFn))
+	l.Close()
+
+	res, err := f.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, 55, res)
+
+	_, err = l.Submit(context.Background(), "a", IgnoreContext(fastFn))
+	assert.Equal(t, ErrShutdown, err)
+	_, err = l.TrySubmit(context.Background(), "a", IgnoreContext(fastFn))
+	assert.Equal(t, ErrShutdown, err)
+}
+
+func TestLanesJoinedFromPool(t *testing.T) {
+	l := NewLanes(1)
+	defer l.Close()
+	p := NewPool(2)
+	defer p.Close()
+
+	res, err := p.Invoke(context.Background(), func(w *Worker) (interface{}, error) {
+		f, err := l.Submit(w.Context(), "a", IgnoreContext(fastFn))
+		if err != nil {
+			return nil, err
+		}
+		return w.Join(f)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 55, res)
+}
@@ -0,0 +1,365 @@
+package paralyze
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Limiter bounds how many tasks run at once, or rather their total Weight.
+// Tasks that can't start right away wait in a queue and are started in the
+// order decided by the Limiter's Policy as running tasks finish. A Limiter
+// can be shared by any number of batches and Executors (see WithLimiter), in
+// which case their tasks all wait in the same queue.
+type Limiter struct {
+	policy Policy
+	clock  Clock
+	epoch  time.Time
+	alg    LimitAlgorithm
+
+	maxQueue int
+	maxWait  time.Duration
+	codel    *codel
+	quota    int
+
+	mu       sync.Mutex
+	limit    int64
+	inUse    int64
+	seq      uint64
+	queue    waitQueue
+	callers  map[string]int
+	rejected uint64
+}
+
+// LimiterOption configures a Limiter.
+type LimiterOption func(*Limiter)
+
+// NewLimiter returns a Limiter that runs at most limit tasks at once, or any
+// number if limit isn't positive. By default, tasks start in the order they
+// were submitted.
+func NewLimiter(limit int, opts ...LimiterOption) *Limiter {
+	l := &Limiter{
+		limit:    int64(limit),
+		policy:   FIFO(),
+		clock:    systemClock{},
+		maxQueue: -1,
+		callers:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.epoch = l.clock.Now()
+	l.queue.l = l
+	return l
+}
+
+// Schedule makes a Limiter start waiting tasks in the order decided by p.
+func Schedule(p Policy) LimiterOption {
+	return func(l *Limiter) { l.policy = p }
+}
+
+// LimiterClock makes a Limiter use c instead of the system clock to tell how
+// long tasks have been waiting.
+func LimiterClock(c Clock) LimiterOption {
+	return func(l *Limiter) { l.clock = c }
+}
+
+// Limit returns the Limiter's current limit, which only changes if it's
+// Adaptive.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Running returns the total weight of the tasks the Limiter has let start
+// that haven't finished yet.
+func (l *Limiter) Running() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.inUse)
+}
+
+// Queued returns the number of tasks waiting to start.
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queue.Len()
+}
+
+// Rejected returns the number of tasks the Limiter has turned away with
+// ErrRejected.
+func (l *Limiter) Rejected() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.rejected)
+}
+
+// waiter is a task's place in a Limiter's queue.
+type waiter struct {
+	task   *Task
+	weight int64
+	seq    uint64
+
+	// caller is who the task is run for, for quotas.
+	caller string
+
+	// at is when the task was submitted, relative to the Limiter's epoch.
+	at time.Duration
+
+	// pos is the waiter's index in the queue, or -1 if it isn't queued.
+	pos int
+
+	// granted is when the waiter was given room.
+	granted time.Time
+
+	// cost is the task's expected duration, for LongestFirst.
+	cost time.Duration
+
+	// grant is called, without the Limiter's lock held, once the task may
+	// start.
+	grant func()
+
+	// reject is called instead of grant, also without the lock held, if
+	// the Limiter turns the task away or drops it for being too late.
+	reject func(err error)
+
+	// err is set on waiters that have been rejected but not told yet.
+	err error
+
+	// timer rejects the waiter once it has waited too long.
+	timer Timer
+}
+
+// notify tells w whether it was granted or rejected.
+func (w *waiter) notify() {
+	if w.err == nil {
+		w.grant()
+	} else if w.reject != nil {
+		w.reject(w.err)
+	}
+}
+
+// submit grants w right away if there's room and nothing is waiting ahead of
+// it, queues it if there's room in the queue, or rejects it otherwise.
+func (l *Limiter) submit(w *waiter) {
+	l.mu.Lock()
+	l.seq++
+	w.seq = l.seq
+	w.at = l.clock.Now().Sub(l.epoch)
+	w.pos = -1
+	if p, ok := l.policy.(preparer); ok {
+		p.prepare(w)
+	}
+	if w.caller != "" && l.quota > 0 && l.callers[w.caller] >= l.quota {
+		l.rejectLocked(w)
+		l.mu.Unlock()
+		w.notify()
+		return
+	}
+	if l.expired(w, l.clock.Now()) {
+		w.err = ErrTimedOut
+		l.mu.Unlock()
+		w.notify()
+		return
+	}
+	l.join(w)
+	if l.queue.Len() == 0 && l.fits(w) {
+		l.take(w)
+		l.mu.Unlock()
+		w.grant()
+		return
+	}
+	if l.maxQueue >= 0 && l.queue.Len() >= l.maxQueue {
+		l.leave(w)
+		l.rejectLocked(w)
+		l.mu.Unlock()
+		w.notify()
+		return
+	}
+	heap.Push(&l.queue, w)
+	if l.maxWait > 0 {
+		w.timer = l.clock.AfterFunc(l.maxWait, func() { l.expire(w) })
+	}
+	l.mu.Unlock()
+}
+
+// remove takes w out of the queue. It reports false if w isn't queued, which
+// means it has been granted or rejected, or was never submitted.
+func (l *Limiter) remove(w *waiter) bool {
+	l.mu.Lock()
+	if w.pos < 0 {
+		l.mu.Unlock()
+		return false
+	}
+	l.unqueue(w)
+	l.leave(w)
+	ready := l.ready()
+	l.mu.Unlock()
+
+	for _, r := range ready {
+		r.notify()
+	}
+	return true
+}
+
+// expire rejects w if it's still waiting once it has waited as long as the
+// Limiter allows.
+func (l *Limiter) expire(w *waiter) {
+	l.mu.Lock()
+	if w.pos < 0 {
+		l.mu.Unlock()
+		return
+	}
+	l.unqueue(w)
+	l.leave(w)
+	l.rejectLocked(w)
+	ready := l.ready()
+	l.mu.Unlock()
+
+	w.notify()
+	for _, r := range ready {
+		r.notify()
+	}
+}
+
+// finish releases a granted waiter whose task ran and returned err, letting
+// an Adaptive Limiter learn from it first.
+func (l *Limiter) finish(w *waiter, err error) {
+	if l.alg != nil {
+		l.mu.Lock()
+		s := Sample{
+			Latency:  l.clock.Now().Sub(w.granted),
+			InFlight: int(l.inUse),
+			Err:      err,
+		}
+		l.limit = int64(l.alg.Update(int(l.limit), s))
+		l.mu.Unlock()
+	}
+	l.release(w)
+}
+
+// release gives back the room taken by a granted waiter and starts whatever
+// can start in its place.
+func (l *Limiter) release(w *waiter) {
+	l.mu.Lock()
+	l.inUse -= w.weight
+	l.leave(w)
+	ready := l.ready()
+	l.mu.Unlock()
+
+	for _, r := range ready {
+		r.notify()
+	}
+}
+
+// ready pops the waiters that can start now, along with any that are shed or
+// expire on the way. Waiters are only ever started from the front of the queue, so a
+// task that has to wait for a lot of room to free up isn't overtaken
+// indefinitely. It must be called with l.mu held.
+func (l *Limiter) ready() []*waiter {
+	var ready []*waiter
+	now := l.clock.Now()
+	for l.queue.Len() > 0 {
+		w := l.queue.ws[0]
+		expired := l.expired(w, now)
+		if !expired && !l.fits(w) {
+			break
+		}
+		l.unqueue(w)
+		switch {
+		case expired:
+			l.leave(w)
+			w.err = ErrTimedOut
+		case l.codel != nil && l.codel.shed(now.Sub(l.epoch)-w.at, now):
+			l.leave(w)
+			l.rejectLocked(w)
+		default:
+			l.take(w)
+		}
+		ready = append(ready, w)
+	}
+	return ready
+}
+
+// take gives w room. It must be called with l.mu held.
+func (l *Limiter) take(w *waiter) {
+	l.inUse += w.weight
+	w.granted = l.clock.Now()
+}
+
+// unqueue takes w out of the queue. It must be called with l.mu held.
+func (l *Limiter) unqueue(w *waiter) {
+	heap.Remove(&l.queue, w.pos)
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// join counts w against its caller's quota until it leaves, i.e. while it's
+// queued or running. It must be called with l.mu held.
+func (l *Limiter) join(w *waiter) {
+	if w.caller != "" {
+		l.callers[w.caller]++
+	}
+}
+
+// leave must be called with l.mu held.
+func (l *Limiter) leave(w *waiter) {
+	if w.caller == "" {
+		return
+	}
+	if l.callers[w.caller]--; l.callers[w.caller] <= 0 {
+		delete(l.callers, w.caller)
+	}
+}
+
+// expired reports whether w is too late to start, according to the policy.
+func (l *Limiter) expired(w *waiter, now time.Time) bool {
+	e, ok := l.policy.(expirer)
+	return ok && e.expired(w, now)
+}
+
+// rejectLocked marks w as rejected, to be told once l.mu is released.
+func (l *Limiter) rejectLocked(w *waiter) {
+	w.err = ErrRejected
+	l.rejected++
+}
+
+// fits reports whether w can start now. A waiter heavier than the limit fits
+// once nothing else is running, so it isn't stuck forever. It must be called
+// with l.mu held.
+func (l *Limiter) fits(w *waiter) bool {
+	return l.limit <= 0 || l.inUse == 0 || l.inUse+w.weight <= l.limit
+}
+
+// waitQueue is a heap of waiters ordered by their Limiter's policy.
+type waitQueue struct {
+	l  *Limiter
+	ws []*waiter
+}
+
+func (q *waitQueue) Len() int { return len(q.ws) }
+
+func (q *waitQueue) Less(i, j int) bool { return q.l.policy.before(q.ws[i], q.ws[j]) }
+
+func (q *waitQueue) Swap(i, j int) {
+	q.ws[i], q.ws[j] = q.ws[j], q.ws[i]
+	q.ws[i].pos = i
+	q.ws[j].pos = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.pos = len(q.ws)
+	q.ws = append(q.ws, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	n := len(q.ws)
+	w := q.ws[n-1]
+	q.ws[n-1] = nil
+	q.ws = q.ws[:n-1]
+	w.pos = -1
+	return w
+}
@@ -0,0 +1,214 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitForQueued waits until n tasks are waiting in l.
+func waitForQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); l.Queued() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d tasks queued, want %d", l.Queued(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	l := NewLimiter(1, Schedule(ByPriority(0)))
+	e := NewExecutor(WithLimiter(l))
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []int
+	task := func(priority int) Task {
+		return Task{Priority: priority, Fn: func(context.Context) (interface{}, error) {
+			if priority == 0 {
+				<-release
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+			return priority, nil
+		}}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.Run(context.Background(), 0, task(0), task(1), task(3), task(2))
+		assert.Equal(t, []interface{}{0, 1, 3, 2}, results)
+		assert.Equal(t, []error{nil, nil, nil, nil}, errs)
+	}()
+
+	waitForQueued(t, l, 3)
+	assert.Equal(t, 1, l.Running())
+	close(release)
+	<-done
+
+	assert.Equal(t, []int{0, 3, 2, 1}, order)
+	assert.Equal(t, 0, l.Running())
+}
+
+func TestLimiterShared(t *testing.T) {
+	l := NewLimiter(2)
+	e := NewExecutor(WithLimiter(l))
+
+	var running, most int64
+	fn := func() (interface{}, error) {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			m := atomic.LoadInt64(&most)
+			if n <= m || atomic.CompareAndSwapInt64(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return nil, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Paralyze(fn, fn, fn)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(2), most)
+	assert.Equal(t, 0, l.Running())
+	assert.Equal(t, 0, l.Queued())
+}
+
+func TestRunCanceledWhileQueued(t *testing.T) {
+	l := NewLimiter(1)
+	e := NewExecutor(WithLimiter(l))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	var ran int64
+	blocked := func(context.Context) (interface{}, error) {
+		<-release
+		return "first", nil
+	}
+	queued := func(context.Context) (interface{}, error) {
+		atomic.AddInt64(&ran, 1)
+		return "queued", nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.ParalyzeWithContext(ctx, blocked, queued, queued)
+		assert.Equal(t, []interface{}{"first", nil, nil}, results)
+		assert.Equal(t, []error{nil, ErrCanceled, ErrCanceled}, errs)
+	}()
+
+	waitForQueued(t, l, 2)
+	cancel()
+	waitForQueued(t, l, 0)
+	close(release)
+	<-done
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&ran))
+	assert.Equal(t, 0, l.Running())
+}
+
+func TestRunLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	slow := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	results, errs := NewExecutor().Run(ctx, 1, Task{Fn: slow}, Task{Fn: IgnoreContext(fastFn)})
+	assert.Equal(t, []interface{}{nil, nil}, results)
+	assert.Equal(t, []error{context.DeadlineExceeded, ErrTimedOut}, errs)
+}
+
+func TestParalyzePriority(t *testing.T) {
+	results, errs := ParalyzePriority(2,
+		Task{Fn: IgnoreContext(fastFn)},
+		Task{Fn: IgnoreContext(errFn), Priority: 1},
+	)
+	assert.Equal(t, []interface{}{55, nil}, results)
+	assert.Equal(t, []error{nil, someError}, errs)
+}
+
+func TestParalyzeWeighted(t *testing.T) {
+	var inFlight, most int64
+	task := func(weight int) Task {
+		return Task{Weight: weight, Fn: func(context.Context) (interface{}, error) {
+			n := atomic.AddInt64(&inFlight, int64(weight))
+			for {
+				m := atomic.LoadInt64(&most)
+				if n <= m || atomic.CompareAndSwapInt64(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt64(&inFlight, -int64(weight))
+			return weight, nil
+		}}
+	}
+
+	results, errs := ParalyzeWeighted(context.Background(), 10, task(6), task(6), task(3), task(1), task(12))
+	assert.Equal(t, []interface{}{6, 6, 3, 1, 12}, results)
+	assert.Equal(t, []error{nil, nil, nil, nil, nil}, errs)
+	// The task heavier than the budget ran on its own.
+	assert.Equal(t, int64(12), most)
+}
+
+func TestLimiterHeavyNotStarved(t *testing.T) {
+	l := NewLimiter(10)
+
+	var order []int
+	submit := func(weight int) *waiter {
+		w := &waiter{task: &Task{Weight: weight}, weight: int64(weight)}
+		w.grant = func() { order = append(order, weight) }
+		l.submit(w)
+		return w
+	}
+
+	first := submit(4)
+	heavy := submit(8)
+	light := submit(1)
+	assert.Equal(t, []int{4}, order)
+
+	// The light task would fit, but it doesn't get ahead of the heavy one.
+	assert.Equal(t, 2, l.Queued())
+	l.release(first)
+	assert.Equal(t, []int{4, 8, 1}, order)
+	assert.Equal(t, 9, l.Running())
+
+	l.release(heavy)
+	l.release(light)
+	assert.Equal(t, 0, l.Running())
+}
+
+func TestParalyzeWeightedCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := func(ctx context.Context) (interface{}, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	results, errs := ParalyzeWeighted(ctx, 5,
+		Task{Fn: blocked, Weight: 3},
+		Task{Fn: IgnoreContext(fastFn), Weight: 3},
+	)
+	assert.Equal(t, []interface{}{nil, nil}, results)
+	assert.Equal(t, []error{context.Canceled, ErrCanceled}, errs)
+}
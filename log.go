@@ -0,0 +1,136 @@
+package paralyze
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LogOptions controls the records written by WithLogger. The zero value uses
+// the defaults documented on each field.
+type LogOptions struct {
+	// BatchLevel is the level of the records written when a batch starts and
+	// finishes. Defaults to slog.LevelDebug.
+	BatchLevel slog.Leveler
+
+	// FailureLevel is the level of the record written when a task returns an
+	// error. Defaults to slog.LevelWarn.
+	FailureLevel slog.Leveler
+
+	// PanicLevel is the level of the record written when a task panics.
+	// Defaults to slog.LevelError.
+	PanicLevel slog.Leveler
+
+	// CancelLevel is the level of the records written when a task or batch
+	// times out or is canceled. Defaults to slog.LevelInfo.
+	CancelLevel slog.Leveler
+
+	// Attrs, if not nil, is called with the batch's context and the
+	// attributes it returns are added to every record for that batch.
+	Attrs func(context.Context) []slog.Attr
+}
+
+// WithLogger makes an Executor write structured logs to l when a batch starts
+// and finishes, when a task fails or panics, and when tasks time out or are
+// canceled. Records are written with the context passed to
+// ParalyzeWithContext, or the one given to WithBaseContext for every other
+// kind of batch. If o is nil, the defaults are used.
+func WithLogger(l *slog.Logger, o *LogOptions) Option {
+	lo := &logObserver{l: l}
+	if o != nil {
+		lo.LogOptions = *o
+	}
+	if lo.BatchLevel == nil {
+		lo.BatchLevel = slog.LevelDebug
+	}
+	if lo.FailureLevel == nil {
+		lo.FailureLevel = slog.LevelWarn
+	}
+	if lo.PanicLevel == nil {
+		lo.PanicLevel = slog.LevelError
+	}
+	if lo.CancelLevel == nil {
+		lo.CancelLevel = slog.LevelInfo
+	}
+	return func(e *Executor) { e.observers = append(e.observers, lo) }
+}
+
+type logObserver struct {
+	LogOptions
+	l *slog.Logger
+}
+
+func (lo *logObserver) batchStarted(b *batch) {
+	lo.log(b, lo.BatchLevel, "paralyze batch started")
+}
+
+func (lo *logObserver) taskStarted(b *batch, i int) {}
+
+func (lo *logObserver) taskReturned(b *batch, i int) {}
+
+func (lo *logObserver) taskFinished(b *batch, i int, err error) {
+	if err == nil {
+		return
+	}
+	level, msg := lo.FailureLevel, "paralyze task failed"
+	if isCanceled(err) {
+		level, msg = lo.CancelLevel, "paralyze task canceled"
+	}
+	lo.log(b, level, msg, lo.taskAttrs(b, i, slog.Any("error", err))...)
+}
+
+func (lo *logObserver) taskPanicked(b *batch, i int, r interface{}, stack []byte) {
+	lo.log(b, lo.PanicLevel, "paralyze task panicked", lo.taskAttrs(b, i,
+		slog.String("panic", fmt.Sprint(r)),
+		slog.String("stack", string(stack)),
+	)...)
+}
+
+func (lo *logObserver) batchFinished(b *batch, errs []error) {
+	var failed, canceled int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case isCanceled(err):
+			canceled++
+		default:
+			failed++
+		}
+	}
+
+	level, msg := lo.BatchLevel, "paralyze batch finished"
+	if canceled > 0 {
+		level, msg = lo.CancelLevel, "paralyze batch canceled"
+		if b.kind == kindTimeout {
+			msg = "paralyze batch timed out"
+		}
+	}
+	lo.log(b, level, msg,
+		slog.Int("failed", failed),
+		slog.Int("canceled", canceled),
+		slog.Duration("duration", b.since(b.start)),
+	)
+}
+
+func (lo *logObserver) taskAttrs(b *batch, i int, attrs ...slog.Attr) []slog.Attr {
+	attrs = append(attrs, slog.Int("task", i))
+	if key := b.key(i); key != "" {
+		attrs = append(attrs, slog.String("key", key))
+	}
+	return append(attrs, slog.Duration("elapsed", b.since(b.start)))
+}
+
+func (lo *logObserver) log(b *batch, level slog.Leveler, msg string, attrs ...slog.Attr) {
+	ctx := b.ctx
+	if !lo.l.Enabled(ctx, level.Level()) {
+		return
+	}
+	attrs = append(attrs, slog.String("kind", b.kind), slog.Int("tasks", b.size))
+	if b.label != "" {
+		attrs = append(attrs, slog.String("label", b.label))
+	}
+	if lo.Attrs != nil {
+		attrs = append(attrs, lo.Attrs(ctx)...)
+	}
+	lo.l.LogAttrs(ctx, level.Level(), msg, attrs...)
+}
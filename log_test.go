@@ -0,0 +1,128 @@
+package paralyze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func findRecord(records []map[string]interface{}, msg string) map[string]interface{} {
+	for _, rec := range records {
+		if rec["msg"] == msg {
+			return rec
+		}
+	}
+	return nil
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-1")
+	e := NewExecutor(
+		WithLabel("fetch"),
+		WithLogger(newTestLogger(&buf), &LogOptions{
+			Attrs: func(ctx context.Context) []slog.Attr {
+				return []slog.Attr{slog.Any("request", ctx.Value(ctxKey{}))}
+			},
+		}),
+	)
+
+	e.ParalyzeWithContext(ctx, func(context.Context) (interface{}, error) {
+		return nil, someError
+	}, func(context.Context) (interface{}, error) {
+		return 1, nil
+	})
+
+	records := logRecords(t, &buf)
+	assert.Equal(t, 3, len(records))
+
+	start := findRecord(records, "paralyze batch started")
+	assert.Equal(t, "DEBUG", start["level"])
+	assert.Equal(t, "fetch", start["label"])
+	assert.Equal(t, "context", start["kind"])
+	assert.Equal(t, "req-1", start["request"])
+
+	failed := findRecord(records, "paralyze task failed")
+	assert.Equal(t, "WARN", failed["level"])
+	assert.Equal(t, "some error", failed["error"])
+	assert.Equal(t, float64(0), failed["task"])
+
+	end := findRecord(records, "paralyze batch finished")
+	assert.Equal(t, float64(1), end["failed"])
+	assert.Equal(t, "req-1", end["request"])
+}
+
+func TestWithLoggerTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewExecutor(WithLogger(newTestLogger(&buf), nil))
+
+	block := make(chan struct{})
+	defer close(block)
+	e.ParalyzeWithTimeout(10*time.Millisecond, fastFn, func() (interface{}, error) {
+		<-block
+		return nil, nil
+	})
+
+	records := logRecords(t, &buf)
+	canceled := findRecord(records, "paralyze task canceled")
+	assert.Equal(t, "INFO", canceled["level"])
+	assert.Equal(t, "timed out", canceled["error"])
+	assert.Equal(t, float64(1), canceled["task"])
+
+	end := findRecord(records, "paralyze batch timed out")
+	assert.Equal(t, "INFO", end["level"])
+	assert.Equal(t, float64(1), end["canceled"])
+}
+
+func TestWithLoggerPanic(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewExecutor(WithLogger(newTestLogger(&buf), &LogOptions{
+		BatchLevel: slog.LevelInfo,
+		PanicLevel: slog.LevelWarn,
+	}))
+
+	assert.Panics(t, func() {
+		e.ParalyzeLimit(1, func() (interface{}, error) { panic("whoops") })
+	})
+
+	records := logRecords(t, &buf)
+	panicked := findRecord(records, "paralyze task panicked")
+	assert.Equal(t, "WARN", panicked["level"])
+	assert.Equal(t, "whoops", panicked["panic"])
+	assert.Contains(t, panicked["stack"], "TestWithLoggerPanic")
+	assert.Equal(t, "INFO", findRecord(records, "paralyze batch started")["level"])
+}
+
+func TestWithLoggerKeys(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewExecutor(WithLogger(newTestLogger(&buf), nil))
+
+	e.ParalyzeM(map[string]Paralyzable{"bad": errFn})
+
+	failed := findRecord(logRecords(t, &buf), "paralyze task failed")
+	assert.Equal(t, "bad", failed["key"])
+}
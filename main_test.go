@@ -0,0 +1,11 @@
+package paralyze_test
+
+import (
+	"testing"
+
+	"github.com/i/paralyze/paralyzetest"
+)
+
+func TestMain(m *testing.M) {
+	paralyzetest.VerifyTestMain(m)
+}
@@ -0,0 +1,101 @@
+package paralyze
+
+import (
+	"context"
+	"time"
+)
+
+// Memo caches the results of keyed tasks, so repeated fan-outs skip work
+// whose results were computed recently. Concurrent calls for a key that isn't
+// cached share a single execution.
+type Memo struct {
+	// Cache stores results. It must be set.
+	Cache Cache
+
+	// MaxAge is how long a result is used without running its task again.
+	// If zero, results are used for as long as Cache holds them.
+	MaxAge time.Duration
+
+	// StaleWhileRevalidate extends MaxAge: a result that's older than MaxAge
+	// by less than this is still returned, but its task is run again in the
+	// background to refresh it.
+	StaleWhileRevalidate time.Duration
+
+	// CacheErrors makes failures cacheable too, so a task that just failed
+	// isn't retried until its result goes stale. Cancellations and timeouts
+	// are never cached.
+	CacheErrors bool
+
+	// Clock dates results. If nil, the system clock is used.
+	Clock Clock
+
+	flight Dedup
+}
+
+// Wrap returns a function that returns key's cached result, or runs fn to
+// compute it.
+func (m *Memo) Wrap(key string, fn Paralyzable) Paralyzable {
+	wrapped := m.WrapCtx(key, ignoreCtx(fn))
+	return func() (interface{}, error) { return wrapped(context.Background()) }
+}
+
+// WrapCtx is the same as Wrap, for functions that take a context. Background
+// refreshes run with a context that keeps ctx's values but isn't canceled
+// with it.
+func (m *Memo) WrapCtx(key string, fn ParalyzableCtx) ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		return m.do(ctx, key, fn)
+	}
+}
+
+// WrapM wraps every function in tasks by its key, ready for ParalyzeM:
+//
+//	results := paralyze.ParalyzeM(memo.WrapM(tasks))
+func (m *Memo) WrapM(tasks map[string]Paralyzable) map[string]Paralyzable {
+	wrapped := make(map[string]Paralyzable, len(tasks))
+	for key, fn := range tasks {
+		wrapped[key] = m.Wrap(key, fn)
+	}
+	return wrapped
+}
+
+func (m *Memo) do(ctx context.Context, key string, fn ParalyzableCtx) (interface{}, error) {
+	if entry, ok := m.Cache.Get(key); ok {
+		age := m.clock().Now().Sub(entry.Created)
+		switch {
+		case m.MaxAge <= 0 || age < m.MaxAge:
+			return entry.Res, entry.Err
+		case age < m.MaxAge+m.StaleWhileRevalidate:
+			go m.refresh(context.WithoutCancel(ctx), key, fn)
+			return entry.Res, entry.Err
+		}
+	}
+
+	res, err, _ := m.flight.Do(ctx, key, m.load(key, fn))
+	return res, err
+}
+
+// refresh reloads key in the background. A panic in fn, which callers
+// waiting on the same load still see, leaves the stale entry in place.
+func (m *Memo) refresh(ctx context.Context, key string, fn ParalyzableCtx) {
+	defer func() { recover() }()
+	m.flight.Do(ctx, key, m.load(key, fn))
+}
+
+// load runs fn and stores its result.
+func (m *Memo) load(key string, fn ParalyzableCtx) ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		res, err := fn(ctx)
+		if err == nil || (m.CacheErrors && !isCanceled(err)) {
+			m.Cache.Set(key, CacheEntry{Res: res, Err: err, Created: m.clock().Now()})
+		}
+		return res, err
+	}
+}
+
+func (m *Memo) clock() Clock {
+	if m.Clock == nil {
+		return systemClock{}
+	}
+	return m.Clock
+}
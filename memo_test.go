@@ -0,0 +1,158 @@
+package paralyze
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// counter returns a task that counts its calls and returns the count.
+func counter(calls *int32) Paralyzable {
+	return func() (interface{}, error) {
+		return int(atomic.AddInt32(calls, 1)), nil
+	}
+}
+
+func TestMemo(t *testing.T) {
+	clock := &manualClock{}
+	m := &Memo{Cache: NewLRUCache(10), MaxAge: time.Minute, Clock: clock}
+
+	var a, b int32
+	tasks := map[string]Paralyzable{"a": counter(&a), "b": counter(&b)}
+
+	results := ParalyzeM(m.WrapM(tasks))
+	assert.Equal(t, 1, results["a"].Res)
+	assert.Equal(t, 1, results["b"].Res)
+
+	results = ParalyzeM(m.WrapM(tasks))
+	assert.Equal(t, 1, results["a"].Res)
+	assert.Equal(t, int32(1), a)
+
+	clock.advance(time.Minute)
+	results = ParalyzeM(m.WrapM(tasks))
+	assert.Equal(t, 2, results["a"].Res)
+	assert.Equal(t, 2, results["b"].Res)
+}
+
+func TestMemoErrors(t *testing.T) {
+	var calls int32
+	failing := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, someError
+	}
+
+	m := &Memo{Cache: NewLRUCache(10)}
+	m.Wrap("k", failing)()
+	_, err := m.Wrap("k", failing)()
+	assert.Equal(t, someError, err)
+	assert.Equal(t, int32(2), calls)
+
+	m = &Memo{Cache: NewLRUCache(10), CacheErrors: true}
+	m.Wrap("k", failing)()
+	_, err = m.Wrap("k", failing)()
+	assert.Equal(t, someError, err)
+	assert.Equal(t, int32(3), calls)
+
+	m.Wrap("timeout", func() (interface{}, error) { return nil, ErrTimedOut })()
+	_, ok := m.Cache.Get("timeout")
+	assert.False(t, ok)
+}
+
+func TestMemoStaleWhileRevalidate(t *testing.T) {
+	clock := &manualClock{}
+	m := &Memo{
+		Cache:                NewLRUCache(10),
+		MaxAge:               time.Minute,
+		StaleWhileRevalidate: time.Minute,
+		Clock:                clock,
+	}
+
+	var calls int32
+	refreshed := make(chan struct{}, 1)
+	fn := m.WrapCtx("k", func(context.Context) (interface{}, error) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil
+	})
+
+	res, _ := fn(context.Background())
+	assert.Equal(t, 1, res)
+
+	clock.advance(90 * time.Second)
+	res, _ = fn(context.Background())
+	assert.Equal(t, 1, res, "stale result is served")
+	<-refreshed
+	for {
+		if entry, _ := m.Cache.Get("k"); entry.Res == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	res, _ = fn(context.Background())
+	assert.Equal(t, 2, res)
+
+	clock.advance(2 * time.Minute)
+	res, _ = fn(context.Background())
+	assert.Equal(t, 3, res, "results past the stale window are recomputed")
+}
+
+func TestMemoRefreshPanics(t *testing.T) {
+	clock := &manualClock{}
+	m := &Memo{
+		Cache:                NewLRUCache(10),
+		MaxAge:               time.Minute,
+		StaleWhileRevalidate: time.Minute,
+		Clock:                clock,
+	}
+
+	var calls int32
+	panicked := make(chan struct{})
+	fn := m.WrapCtx("k", func(context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			close(panicked)
+			panic("boom")
+		}
+		return "fresh", nil
+	})
+
+	res, _ := fn(context.Background())
+	assert.Equal(t, "fresh", res)
+
+	clock.advance(90 * time.Second)
+	res, _ = fn(context.Background())
+	assert.Equal(t, "fresh", res)
+	<-panicked
+	for {
+		m.flight.mu.Lock()
+		n := len(m.flight.calls)
+		m.flight.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	entry, ok := m.Cache.Get("k")
+	assert.True(t, ok, "the stale entry is kept")
+	assert.Equal(t, "fresh", entry.Res)
+}
+
+func TestMemoWithTTLCache(t *testing.T) {
+	clock := &manualClock{}
+	m := &Memo{Cache: NewTTLCache(time.Minute, clock)}
+
+	var calls int32
+	fn := m.Wrap("k", counter(&calls))
+	fn()
+	fn()
+	assert.Equal(t, int32(1), calls)
+
+	clock.advance(time.Minute)
+	res, _ := fn()
+	assert.Equal(t, 2, res)
+}
@@ -3,7 +3,6 @@ package paralyze
 import (
 	"context"
 	"errors"
-	"sync"
 	"time"
 )
 
@@ -21,38 +20,15 @@ type ParalyzableCtx func(context.Context) (interface{}, error)
 var (
 	ErrTimedOut = errors.New("timed out")
 	ErrCanceled = errors.New("canceled")
+	ErrRejected = errors.New("rejected")
+	ErrShutdown = errors.New("shut down")
 )
 
 // Paralyze parallelizes a function and returns a slice containing results and
 // a slice containing errors. The results at each index are not mutually exclusive,
 // that is if results[i] is not nil, errors[i] is not guaranteed to be nil.
 func Paralyze(funcs ...Paralyzable) (results []interface{}, errors []error) {
-	var wg sync.WaitGroup
-	results = make([]interface{}, len(funcs))
-	errors = make([]error, len(funcs))
-	wg.Add(len(funcs))
-
-	var panik interface{}
-	var panikOnce sync.Once
-
-	for i, fn := range funcs {
-		go func(i int, fn Paralyzable) {
-			defer func() {
-				if r := recover(); r != nil {
-					panikOnce.Do(func() { panik = r })
-				}
-			}()
-			defer wg.Done()
-			results[i], errors[i] = fn()
-		}(i, fn)
-	}
-	wg.Wait()
-
-	if panik != nil {
-		panic(panik)
-	}
-
-	return results, errors
+	return std.Paralyze(funcs...)
 }
 
 type ResErr struct {
@@ -63,25 +39,7 @@ type ResErr struct {
 // ParalyzeM parallelizes a map of strings to functions. The return type is a
 // map of keys to a map containing two keys: res and err.
 func ParalyzeM(m map[string]Paralyzable) map[string]ResErr {
-	var names []string
-	var fns []Paralyzable
-
-	for name, fn := range m {
-		names = append(names, name)
-		fns = append(fns, fn)
-	}
-
-	res := make(map[string]ResErr)
-	results, errs := Paralyze(fns...)
-	for i := range results {
-		name := names[i]
-		res[name] = ResErr{
-			Res: results[i],
-			Err: errs[i],
-		}
-	}
-
-	return res
+	return std.ParalyzeM(m)
 }
 
 // ParalyzeWithTimeout does the same as Paralyze, but it accepts a timeout. If
@@ -89,110 +47,72 @@ func ParalyzeM(m map[string]Paralyzable) map[string]ResErr {
 // unfinished results will be discarded without being cancelled. Any complete
 // tasks will be unaffected.
 func ParalyzeWithTimeout(timeout time.Duration, funcs ...Paralyzable) ([]interface{}, []error) {
-	if timeout == 0 {
-		return Paralyze(funcs...)
-	}
-
-	cancel := make(chan struct{})
-	go time.AfterFunc(timeout, func() { close(cancel) })
-
-	results, errors := ParalyzeWithCancel(cancel, funcs...)
-	for i, err := range errors {
-		if err == ErrCanceled {
-			errors[i] = ErrTimedOut
-		}
-	}
-	return results, errors
+	return std.ParalyzeWithTimeout(timeout, funcs...)
 }
 
 // ParalyzeWithCancel does the same as Paralyze, but it accepts a channel that
 // allows the function to respond before the paralyzed functions are finished.
 // Any functions that are still oustanding will have errors set as ErrCanceled.
 func ParalyzeWithCancel(cancel <-chan struct{}, funcs ...Paralyzable) ([]interface{}, []error) {
-	var wg sync.WaitGroup
-	results := make([]interface{}, len(funcs))
-	errors := make([]error, len(funcs))
-	wg.Add(len(funcs))
-
-	for i, fn := range funcs {
-		go func(i int, fn func() chan ResErr) {
-			defer wg.Done()
-			ch := fn()
-			select {
-			case resErr := <-ch:
-				results[i] = resErr.Res
-				errors[i] = resErr.Err
-			case <-cancel:
-				errors[i] = ErrCanceled
-			}
-		}(i, convert(fn))
-	}
-	wg.Wait()
-	return results, errors
+	return std.ParalyzeWithCancel(cancel, funcs...)
 }
 
 // ParalyzeWithContext takes a slice of functions that accept a
 // context.Context. These functions are responsible for releasing resources
 // (closing connections, etc.) and should respect ctx.Done().
 func ParalyzeWithContext(ctx context.Context, funcs ...ParalyzableCtx) ([]interface{}, []error) {
-	var wg sync.WaitGroup
-	results := make([]interface{}, len(funcs))
-	errors := make([]error, len(funcs))
-
-	wg.Add(len(funcs))
-	for i, fn := range funcs {
-		go func(i int, fn ParalyzableCtx) {
-			defer wg.Done()
-			results[i], errors[i] = fn(ctx)
-		}(i, fn)
-	}
-	wg.Wait()
-	return results, errors
+	return std.ParalyzeWithContext(ctx, funcs...)
 }
 
-func convert(fn func() (interface{}, error)) func() chan ResErr {
-	return func() chan ResErr {
-		ch := make(chan ResErr, 1)
-		go func() {
-			res, err := fn()
-			ch <- ResErr{res, err}
-		}()
-		return ch
-	}
+// ParalyzeWithBudget does the same as ParalyzeWithTimeout, for functions that
+// accept a context.Context. If ctx has a deadline, the timeout is cut short to
+// end before it, so batches started by tasks of other batches stay within the
+// outer batch's time. Tasks are passed a context with the batch's deadline,
+// which is canceled when it passes or ctx is done. If there's no time left,
+// no tasks are started and they all fail with ErrTimedOut. A timeout of zero
+// means the batch is only bounded by ctx.
+func ParalyzeWithBudget(ctx context.Context, timeout time.Duration, funcs ...ParalyzableCtx) ([]interface{}, []error) {
+	return std.ParalyzeWithBudget(ctx, timeout, funcs...)
 }
 
-type paralyzer struct {
-	maxConcurrency int
+// ParalyzeLimit does the same as Paralyze, but runs at most limit functions
+// at a time.
+func ParalyzeLimit(limit int, tasks ...Paralyzable) ([]interface{}, []error) {
+	return std.ParalyzeLimit(limit, tasks...)
 }
 
-func ParalyzeLimit(limit int, tasks ...Paralyzable) ([]interface{}, []error) {
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, limit)
-	results := make([]interface{}, len(tasks))
-	errors := make([]error, len(tasks))
-	wg.Add(len(tasks))
-
-	var panik interface{}
-	var panikOnce sync.Once
-
-	for i, fn := range tasks {
-		sem <- struct{}{}
-		go func(i int, fn Paralyzable) {
-			defer func() {
-				wg.Done()
-				<-sem
-				if r := recover(); r != nil {
-					panikOnce.Do(func() { panik = r })
-				}
-			}()
-			results[i], errors[i] = fn()
-		}(i, fn)
-	}
-	wg.Wait()
-
-	if panik != nil {
-		panic(panik)
-	}
-
-	return results, errors
+// ParalyzePriority does the same as ParalyzeLimit, but when tasks are waiting
+// to start, the ones with the highest Priority start first. Tasks gain a
+// level of priority for every PriorityAging they wait, so low priority tasks
+// aren't starved.
+func ParalyzePriority(limit int, tasks ...Task) ([]interface{}, []error) {
+	return std.ParalyzePriority(limit, tasks...)
+}
+
+// ParalyzeWeighted runs tasks while keeping the total Weight of the ones
+// running at once within budget. A heavy task that has to wait holds up the
+// lighter ones behind it rather than being overtaken indefinitely. Tasks that
+// are still waiting when ctx is done don't run, and fail with ErrCanceled or
+// ErrTimedOut.
+func ParalyzeWeighted(ctx context.Context, budget int, tasks ...Task) ([]interface{}, []error) {
+	return std.ParalyzeWeighted(ctx, budget, tasks...)
+}
+
+// ParalyzeTasks does the same as ParalyzeWithContext, for tasks that need more
+// than a function, e.g. a Partition. Tasks that are still waiting for room in
+// a bulkhead when ctx is done don't run, and fail with ErrCanceled or
+// ErrTimedOut.
+func ParalyzeTasks(ctx context.Context, tasks ...Task) ([]interface{}, []error) {
+	return std.Run(ctx, 0, tasks...)
+}
+
+// RunSaga runs steps in parallel, and undoes the ones that succeeded if
+// another failed. See Executor.RunSaga.
+func RunSaga(ctx context.Context, steps []Step, opts ...SagaOption) ([]interface{}, error) {
+	return std.RunSaga(ctx, steps, opts...)
+}
+
+// Replay runs dead-lettered tasks again. See Executor.Replay.
+func Replay(ctx context.Context, limit int, letters []DeadLetter, rebuild func(DeadLetter) ParalyzableCtx) ([]interface{}, []error) {
+	return std.Replay(ctx, limit, letters, rebuild)
 }
@@ -0,0 +1,125 @@
+package paralyzetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/i/paralyze"
+)
+
+// Clock is a paralyze.Clock whose time only moves when Advance is called.
+// It's safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*timer
+	changed chan struct{}
+}
+
+type timer struct {
+	c    *Clock
+	when time.Time
+	f    func()
+}
+
+// NewClock returns a Clock that starts at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc arranges for f to be called once the clock has been advanced by
+// at least d. f is called from the goroutine that calls Advance.
+func (c *Clock) AfterFunc(d time.Duration, f func()) paralyze.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{c: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	c.notify()
+	return t
+}
+
+// Advance moves the clock forward by d and calls every function that comes
+// due, in the order they are due, before returning.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+	var due []*timer
+	for len(c.timers) > 0 && !c.timers[0].when.After(c.now) {
+		due = append(due, c.timers[0])
+		c.timers = c.timers[1:]
+	}
+	c.notify()
+	c.mu.Unlock()
+
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// Timers returns the number of functions waiting for the clock to advance.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitForTimers blocks until at least n functions are waiting for the clock
+// to advance, which is how a test knows a batch has armed its timeout. It
+// gives up and returns false after WaitTimeout.
+func (c *Clock) WaitForTimers(n int) bool {
+	return waitFor(&c.mu, &c.changed, func() bool { return len(c.timers) >= n })
+}
+
+// notify must be called with c.mu held.
+func (c *Clock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (t *timer) Stop() bool {
+	c := t.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// WaitTimeout is how long the helpers in this package wait for something to
+// happen before giving up.
+var WaitTimeout = 10 * time.Second
+
+// waitFor blocks until cond, which is checked with mu held, is true. *changed
+// must be closed whenever the state cond looks at changes.
+func waitFor(mu *sync.Mutex, changed *chan struct{}, cond func() bool) bool {
+	deadline := time.NewTimer(WaitTimeout)
+	defer deadline.Stop()
+	for {
+		mu.Lock()
+		ok := cond()
+		ch := *changed
+		mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-ch:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package paralyzetest
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/i/paralyze"
+)
+
+// ErrInjected is the error returned by tasks that fail because of Fail with
+// a nil error.
+var ErrInjected = errors.New("paralyzetest: injected failure")
+
+// Fault is a way for a task to misbehave. It's called before the task runs,
+// with the task's context and a source of randomness; if it returns an error
+// the task doesn't run and fails with that error instead.
+type Fault func(ctx context.Context, r *rand.Rand) error
+
+// Latency delays tasks by a random duration between min and max, as told by
+// clock, e.g. a Scheduler's Clock, or real time if clock is nil. A task whose
+// context is done while it's delayed fails with the context's error.
+func Latency(clock paralyze.Clock, min, max time.Duration) Fault {
+	return func(ctx context.Context, r *rand.Rand) error {
+		d := min
+		if max > min {
+			d += time.Duration(r.Int63n(int64(max - min)))
+		}
+		if clock == nil {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-t.C:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		t := clock.AfterFunc(d, func() { close(done) })
+		defer t.Stop()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Fail makes tasks fail with err, or ErrInjected if err is nil, with
+// probability p.
+func Fail(p float64, err error) Fault {
+	if err == nil {
+		err = ErrInjected
+	}
+	return func(ctx context.Context, r *rand.Rand) error {
+		if r.Float64() < p {
+			return err
+		}
+		return nil
+	}
+}
+
+// Panic makes tasks panic with v with probability p.
+func Panic(p float64, v interface{}) Fault {
+	return func(ctx context.Context, r *rand.Rand) error {
+		if r.Float64() < p {
+			panic(v)
+		}
+		return nil
+	}
+}
+
+// Hang makes tasks block until their context is done with probability p.
+// Tasks wrapped with Injector.Wrap have no context, so they block forever;
+// tasks wrapped through Injector.Option are released when their batch gives
+// up on them.
+func Hang(p float64) Fault {
+	return func(ctx context.Context, r *rand.Rand) error {
+		if r.Float64() < p {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+// Injector wraps tasks so they misbehave in the ways given by its faults,
+// which are tried in order until one of them fails the task. All randomness
+// is derived from the seed and from which task is running, by its batch's
+// label and its key or index, and how many times it has run before. So a test
+// that runs the same tasks gets the same faults no matter how its goroutines
+// are scheduled, as long as batches that run at the same time have different
+// labels; see paralyze.WithLabel.
+type Injector struct {
+	seed   int64
+	faults []Fault
+
+	mu      sync.Mutex
+	calls   map[string]int
+	wrapped int
+}
+
+// NewInjector returns an Injector that injects faults using seed.
+func NewInjector(seed int64, faults ...Fault) *Injector {
+	return &Injector{seed: seed, faults: faults, calls: make(map[string]int)}
+}
+
+// Option returns an Option that injects faults into every task run by an
+// Executor, so code under test doesn't need to change.
+func (in *Injector) Option() paralyze.Option {
+	return paralyze.WithMiddleware(in.Middleware)
+}
+
+// Middleware injects faults into fn. It's what Option uses.
+func (in *Injector) Middleware(info paralyze.TaskInfo, fn paralyze.ParalyzableCtx) paralyze.ParalyzableCtx {
+	id := "index:" + strconv.Itoa(info.Index)
+	if info.Key != "" {
+		id = "key:" + info.Key
+	}
+	return in.wrap(info.Batch.Label+"/"+id, fn)
+}
+
+// Wrap returns fn with faults injected. Functions are told apart by the
+// order they were wrapped in.
+func (in *Injector) Wrap(fn paralyze.Paralyzable) paralyze.Paralyzable {
+	wrapped := in.WrapCtx(func(context.Context) (interface{}, error) { return fn() })
+	return func() (interface{}, error) { return wrapped(context.Background()) }
+}
+
+// WrapCtx is the same as Wrap, for functions that take a context.
+func (in *Injector) WrapCtx(fn paralyze.ParalyzableCtx) paralyze.ParalyzableCtx {
+	in.mu.Lock()
+	in.wrapped++
+	id := "wrapped:" + strconv.Itoa(in.wrapped)
+	in.mu.Unlock()
+	return in.wrap(id, fn)
+}
+
+func (in *Injector) wrap(id string, fn paralyze.ParalyzableCtx) paralyze.ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		r := in.rand(id)
+		for _, fault := range in.faults {
+			if err := fault(ctx, r); err != nil {
+				return nil, err
+			}
+		}
+		return fn(ctx)
+	}
+}
+
+// rand returns the source of randomness for the next call of the task
+// identified by id.
+func (in *Injector) rand(id string) *rand.Rand {
+	in.mu.Lock()
+	n := in.calls[id]
+	in.calls[id]++
+	in.mu.Unlock()
+
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h.Write([]byte(strconv.Itoa(n)))
+	return rand.New(rand.NewSource(in.seed ^ int64(h.Sum64())))
+}
@@ -0,0 +1,134 @@
+package paralyzetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i/paralyze"
+	"github.com/stretchr/testify/assert"
+)
+
+func tasks(n int) []paralyze.Paralyzable {
+	fns := make([]paralyze.Paralyzable, n)
+	for i := range fns {
+		fns[i] = value(i)
+	}
+	return fns
+}
+
+func TestInjectorReproducible(t *testing.T) {
+	VerifyNoLeaks(t)
+
+	run := func(seed int64) []error {
+		e := paralyze.NewExecutor(NewInjector(seed, Fail(0.5, nil)).Option())
+		_, errs := e.Paralyze(tasks(50)...)
+		return errs
+	}
+
+	first := run(7)
+	assert.Equal(t, first, run(7))
+	assert.NotEqual(t, first, run(8))
+
+	failed := 0
+	for _, err := range first {
+		if err != nil {
+			assert.Equal(t, ErrInjected, err)
+			failed++
+		}
+	}
+	assert.True(t, failed > 0 && failed < 50)
+}
+
+func TestInjectorFail(t *testing.T) {
+	errDown := errors.New("down")
+	in := NewInjector(1, Fail(1, errDown))
+
+	_, err := in.Wrap(value(1))()
+	assert.Equal(t, errDown, err)
+
+	res, err := NewInjector(1, Fail(0, errDown)).WrapCtx(func(context.Context) (interface{}, error) {
+		return 1, nil
+	})(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res)
+}
+
+func TestInjectorPanic(t *testing.T) {
+	e := paralyze.NewExecutor(NewInjector(1, Panic(1, "chaos")).Option())
+	assert.Panics(t, func() { e.Paralyze(tasks(3)...) })
+}
+
+func TestInjectorHang(t *testing.T) {
+	VerifyNoLeaks(t)
+	e := paralyze.NewExecutor(NewInjector(1, Hang(1)).Option())
+
+	results, errs := e.ParalyzeWithTimeout(10*time.Millisecond, tasks(2)...)
+	assert.Equal(t, []interface{}{nil, nil}, results)
+	assert.Equal(t, []error{paralyze.ErrTimedOut, paralyze.ErrTimedOut}, errs)
+}
+
+func TestInjectorLatency(t *testing.T) {
+	in := NewInjector(1, Latency(nil, 5*time.Millisecond, 10*time.Millisecond))
+
+	start := time.Now()
+	res, err := in.Wrap(value(1))()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res)
+	assert.True(t, time.Since(start) >= 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewInjector(1, Latency(nil, time.Hour, time.Hour)).WrapCtx(func(context.Context) (interface{}, error) {
+		return 1, nil
+	})(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestInjectorLatencyClock(t *testing.T) {
+	VerifyNoLeaks(t)
+	s := NewScheduler(t)
+	in := NewInjector(1, Latency(s.Clock, time.Minute, time.Minute))
+
+	done := make(chan error)
+	go func() {
+		_, err := in.Wrap(value(1))()
+		done <- err
+	}()
+	assert.True(t, s.Clock.WaitForTimers(1))
+	s.Clock.Advance(time.Minute)
+	assert.NoError(t, <-done)
+}
+
+func TestInjectorConcurrentBatches(t *testing.T) {
+	VerifyNoLeaks(t)
+
+	// Each labeled batch gets the faults it would get on its own, whatever
+	// runs beside it.
+	alone := func(label string) []error {
+		in := NewInjector(3, Fail(0.5, nil))
+		_, errs := paralyze.NewExecutor(in.Option(), paralyze.WithLabel(label)).Paralyze(tasks(20)...)
+		return errs
+	}
+	want := map[string][]error{"a": alone("a"), "b": alone("b")}
+
+	in := NewInjector(3, Fail(0.5, nil))
+	got := make(map[string][]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for label := range want {
+		label := label
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs := paralyze.NewExecutor(in.Option(), paralyze.WithLabel(label)).Paralyze(tasks(20)...)
+			mu.Lock()
+			got[label] = errs
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,128 @@
+package paralyzetest
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// LeakTimeout is how long VerifyNoLeaks and VerifyTestMain give goroutines
+// to exit before they are considered leaked.
+var LeakTimeout = 2 * time.Second
+
+// VerifyNoLeaks fails tb if goroutines that involve paralyze are still
+// running once the test and its cleanups are done, e.g. tasks abandoned by
+// ParalyzeWithCancel that never return. Call it first thing in a test, since
+// goroutines that are already running when it's called are ignored.
+func VerifyNoLeaks(tb testing.TB) {
+	tb.Helper()
+	before := goroutineIDs()
+	tb.Cleanup(func() {
+		if leaked := findLeaks(before); len(leaked) > 0 {
+			tb.Error(leakReport(leaked))
+		}
+	})
+}
+
+// VerifyTestMain runs the tests in m and then checks for leaked goroutines
+// like VerifyNoLeaks, failing the run if there are any. Call it from
+// TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		paralyzetest.VerifyTestMain(m)
+//	}
+func VerifyTestMain(m *testing.M) {
+	before := goroutineIDs()
+	code := m.Run()
+	if code == 0 {
+		if leaked := findLeaks(before); len(leaked) > 0 {
+			fmt.Fprintln(os.Stderr, leakReport(leaked))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// goroutine is a single goroutine's entry in a stack dump.
+type goroutine struct {
+	id    string
+	stack string
+}
+
+// fromParalyze reports whether any of g's frames, including the one that
+// created it, are in paralyze or one of its subpackages.
+func (g goroutine) fromParalyze() bool {
+	for _, line := range strings.Split(g.stack, "\n") {
+		line = strings.TrimPrefix(line, "created by ")
+		if strings.HasPrefix(line, "github.com/i/paralyze.") || strings.HasPrefix(line, "github.com/i/paralyze/") {
+			return true
+		}
+	}
+	return false
+}
+
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var gs []goroutine
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		// Each entry starts with "goroutine 7 [chan receive]:".
+		fields := strings.Fields(stack)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+		gs = append(gs, goroutine{id: fields[1], stack: strings.TrimSpace(stack)})
+	}
+	return gs
+}
+
+func goroutineIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, g := range goroutines() {
+		ids[g.id] = true
+	}
+	return ids
+}
+
+// findLeaks returns the goroutines from paralyze that weren't in before,
+// waiting up to LeakTimeout for them to exit.
+func findLeaks(before map[string]bool) []goroutine {
+	deadline := time.Now().Add(LeakTimeout)
+	for {
+		var leaked []goroutine
+		for _, g := range goroutines() {
+			if !before[g.id] && g.fromParalyze() {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// leakReport formats leaked goroutines as additions to the set of goroutines
+// that were running before.
+func leakReport(leaked []goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "paralyzetest: %d goroutine(s) from paralyze leaked:\n", len(leaked))
+	for _, g := range leaked {
+		b.WriteString("\n")
+		for _, line := range strings.Split(g.stack, "\n") {
+			fmt.Fprintf(&b, "+ %s\n", line)
+		}
+	}
+	return b.String()
+}
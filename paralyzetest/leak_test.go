@@ -0,0 +1,75 @@
+package paralyzetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i/paralyze"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTB records the failures reported by the helpers being tested and runs
+// their cleanups on demand.
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Error(args ...interface{}) {
+	for _, arg := range args {
+		f.errors = append(f.errors, arg.(string))
+	}
+}
+
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+
+func (f *fakeTB) runCleanups() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	defer func(d time.Duration) { LeakTimeout = d }(LeakTimeout)
+	LeakTimeout = 50 * time.Millisecond
+
+	leaky, fixed := &fakeTB{TB: t}, &fakeTB{TB: t}
+	VerifyNoLeaks(leaky)
+	VerifyNoLeaks(fixed)
+
+	cancel := make(chan struct{})
+	release := make(chan struct{})
+	close(cancel)
+	paralyze.ParalyzeWithCancel(cancel, func() (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	leaky.runCleanups()
+	assert.Equal(t, 1, len(leaky.errors))
+	assert.Contains(t, leaky.errors[0], "1 goroutine(s) from paralyze leaked")
+	assert.Contains(t, leaky.errors[0], "+ goroutine ")
+	assert.Contains(t, leaky.errors[0], "TestVerifyNoLeaks")
+
+	close(release)
+	fixed.runCleanups()
+	assert.Empty(t, fixed.errors)
+}
+
+func TestGoroutineFromParalyze(t *testing.T) {
+	g := goroutine{stack: strings.Join([]string{
+		"goroutine 7 [chan receive]:",
+		"main.main.func1()",
+		"\t/src/main.go:12 +0x1d",
+		"created by github.com/i/paralyze.(*batch).convert.func1 in goroutine 6",
+		"\t/src/executor.go:320 +0x65",
+	}, "\n")}
+	assert.True(t, g.fromParalyze())
+
+	g = goroutine{stack: "goroutine 8 [select]:\nnet/http.(*persistConn).readLoop()\n\t/go/src/net/http/transport.go:2205"}
+	assert.False(t, g.fromParalyze())
+}
@@ -0,0 +1,220 @@
+// Package paralyzetest helps test code that uses paralyze without relying on
+// real time passing or on goroutines happening to finish in a certain order.
+package paralyzetest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i/paralyze"
+)
+
+// Scheduler controls when the tasks of an Executor run, so a test can choose
+// the order in which they complete. Tasks are held before they start until
+// the test releases them. A held task whose batch gives up on it, e.g.
+// because of a timeout, returns its context's error without running.
+//
+// Batches run by a Scheduler's Executors block until their tasks are
+// released, so they have to be started from another goroutine:
+//
+//	s := paralyzetest.NewScheduler(t)
+//	e := s.Executor()
+//	done := make(chan struct{})
+//	go func() {
+//		results, errs = e.ParalyzeWithTimeout(time.Second, a, b)
+//		close(done)
+//	}()
+//	s.WaitForTasks(2)
+//	s.Complete(1)
+//	s.Clock.Advance(time.Second)
+//	<-done
+type Scheduler struct {
+	// Clock is the clock used by the Scheduler's Executors. It starts at
+	// the time NewScheduler was called.
+	Clock *Clock
+
+	tb      testing.TB
+	mu      sync.Mutex
+	tasks   map[taskID]*task
+	changed chan struct{}
+}
+
+type taskID struct {
+	batch uint64
+	index int
+}
+
+type task struct {
+	info     paralyze.TaskInfo
+	held     bool
+	release  chan struct{}
+	finished bool
+}
+
+// NewScheduler returns a Scheduler that fails tb if it's used incorrectly or
+// a task doesn't do what the test waits for within WaitTimeout.
+func NewScheduler(tb testing.TB) *Scheduler {
+	return &Scheduler{
+		Clock:   NewClock(time.Now()),
+		tb:      tb,
+		tasks:   make(map[taskID]*task),
+		changed: make(chan struct{}),
+	}
+}
+
+// Executor returns an Executor whose tasks are controlled by s and whose
+// clock is s.Clock. opts are applied after the Scheduler's own options.
+func (s *Scheduler) Executor(opts ...paralyze.Option) *paralyze.Executor {
+	return paralyze.NewExecutor(append([]paralyze.Option{
+		paralyze.WithClock(s.Clock),
+		paralyze.WithMiddleware(s.hold),
+		paralyze.WithHooks(paralyze.Hooks{TaskFinished: s.finished}),
+	}, opts...)...)
+}
+
+// Held returns the tasks that are waiting to be released, ordered by batch
+// and then by index.
+func (s *Scheduler) Held() []paralyze.TaskInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var infos []paralyze.TaskInfo
+	for _, t := range s.tasks {
+		if t.held {
+			infos = append(infos, t.info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Batch.ID != infos[j].Batch.ID {
+			return infos[i].Batch.ID < infos[j].Batch.ID
+		}
+		return infos[i].Index < infos[j].Index
+	})
+	return infos
+}
+
+// WaitForTasks blocks until at least n tasks are held.
+func (s *Scheduler) WaitForTasks(n int) {
+	s.tb.Helper()
+	ok := s.waitFor(func() bool {
+		held := 0
+		for _, t := range s.tasks {
+			if t.held {
+				held++
+			}
+		}
+		return held >= n
+	})
+	if !ok {
+		s.tb.Fatalf("paralyzetest: timed out waiting for %d held tasks, have %d", n, len(s.Held()))
+	}
+}
+
+// Complete releases the held task with index i and waits until its batch has
+// settled on its result. It fails the test if there isn't exactly one such
+// task, e.g. because several batches are running; see CompleteTask.
+func (s *Scheduler) Complete(i int) {
+	s.tb.Helper()
+	s.complete(func(info paralyze.TaskInfo) bool { return info.Index == i }, "index %d", i)
+}
+
+// CompleteKey is like Complete, but picks the held task by its ParalyzeM key.
+func (s *Scheduler) CompleteKey(key string) {
+	s.tb.Helper()
+	s.complete(func(info paralyze.TaskInfo) bool { return info.Key == key }, "key %q", key)
+}
+
+// CompleteTask is like Complete, but picks the held task by both its batch
+// and index, as returned by Held.
+func (s *Scheduler) CompleteTask(info paralyze.TaskInfo) {
+	s.tb.Helper()
+	s.complete(func(t paralyze.TaskInfo) bool {
+		return t.Batch.ID == info.Batch.ID && t.Index == info.Index
+	}, "batch %d and index %d", info.Batch.ID, info.Index)
+}
+
+// CompleteAll releases every held task, in order, as if by CompleteTask.
+func (s *Scheduler) CompleteAll() {
+	s.tb.Helper()
+	for _, info := range s.Held() {
+		s.CompleteTask(info)
+	}
+}
+
+func (s *Scheduler) complete(match func(paralyze.TaskInfo) bool, format string, args ...interface{}) {
+	s.tb.Helper()
+	s.mu.Lock()
+	var found []*task
+	for _, t := range s.tasks {
+		if t.held && match(t.info) {
+			found = append(found, t)
+		}
+	}
+	if len(found) != 1 {
+		s.mu.Unlock()
+		s.tb.Fatalf("paralyzetest: want 1 held task with "+format+", have %d", append(args, len(found))...)
+		return
+	}
+	t := found[0]
+	t.held = false
+	close(t.release)
+	s.notify()
+	s.mu.Unlock()
+
+	if !s.waitFor(func() bool { return t.finished }) {
+		s.tb.Fatalf("paralyzetest: timed out waiting for task with "+format+" to finish", args...)
+	}
+}
+
+func (s *Scheduler) hold(info paralyze.TaskInfo, fn paralyze.ParalyzableCtx) paralyze.ParalyzableCtx {
+	return func(ctx context.Context) (interface{}, error) {
+		s.mu.Lock()
+		t := s.task(info)
+		t.held = true
+		s.notify()
+		s.mu.Unlock()
+
+		select {
+		case <-t.release:
+			return fn(ctx)
+		case <-ctx.Done():
+			s.mu.Lock()
+			t.held = false
+			s.notify()
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Scheduler) finished(info paralyze.TaskInfo, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.task(info)
+	t.held = false
+	t.finished = true
+	s.notify()
+}
+
+// task must be called with s.mu held.
+func (s *Scheduler) task(info paralyze.TaskInfo) *task {
+	id := taskID{info.Batch.ID, info.Index}
+	t := s.tasks[id]
+	if t == nil {
+		t = &task{info: info, release: make(chan struct{})}
+		s.tasks[id] = t
+	}
+	return t
+}
+
+// notify must be called with s.mu held.
+func (s *Scheduler) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Scheduler) waitFor(cond func() bool) bool {
+	return waitFor(&s.mu, &s.changed, cond)
+}
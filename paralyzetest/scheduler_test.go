@@ -0,0 +1,150 @@
+package paralyzetest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i/paralyze"
+	"github.com/stretchr/testify/assert"
+)
+
+func value(v interface{}) paralyze.Paralyzable {
+	return func() (interface{}, error) { return v, nil }
+}
+
+func TestSchedulerTimeout(t *testing.T) {
+	VerifyNoLeaks(t)
+	s := NewScheduler(t)
+	e := s.Executor()
+	errBoom := errors.New("boom")
+
+	var results []interface{}
+	var errs []error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs = e.ParalyzeWithTimeout(time.Minute,
+			value("slow"),
+			value("fast"),
+			func() (interface{}, error) { return nil, errBoom },
+		)
+	}()
+
+	s.WaitForTasks(3)
+	s.Complete(1)
+	s.Complete(2)
+	assert.True(t, s.Clock.WaitForTimers(1))
+	s.Clock.Advance(time.Minute)
+	<-done
+
+	assert.Equal(t, []interface{}{nil, "fast", nil}, results)
+	assert.Equal(t, []error{paralyze.ErrTimedOut, nil, errBoom}, errs)
+	assert.Empty(t, s.Held())
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	VerifyNoLeaks(t)
+	s := NewScheduler(t)
+	e := s.Executor()
+
+	var mu sync.Mutex
+	var order []int
+	record := func(i int) paralyze.Paralyzable {
+		return func() (interface{}, error) {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			return i, nil
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Paralyze(record(0), record(1), record(2))
+	}()
+
+	s.WaitForTasks(3)
+	assert.Equal(t, 3, len(s.Held()))
+	s.Complete(2)
+	s.Complete(0)
+	s.Complete(1)
+	<-done
+
+	assert.Equal(t, []int{2, 0, 1}, order)
+}
+
+func TestSchedulerCompleteKey(t *testing.T) {
+	VerifyNoLeaks(t)
+	s := NewScheduler(t)
+	e := s.Executor()
+
+	var results map[string]paralyze.ResErr
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results = e.ParalyzeM(map[string]paralyze.Paralyzable{
+			"a": value(1),
+			"b": value(2),
+		})
+	}()
+
+	s.WaitForTasks(2)
+	s.CompleteKey("b")
+	s.CompleteKey("a")
+	<-done
+
+	assert.Equal(t, 1, results["a"].Res)
+	assert.Equal(t, 2, results["b"].Res)
+}
+
+func TestSchedulerConcurrentBatches(t *testing.T) {
+	VerifyNoLeaks(t)
+	s := NewScheduler(t)
+	e := s.Executor()
+
+	var wg sync.WaitGroup
+	results := make([][]interface{}, 2)
+	for b := range results {
+		b := b
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[b], _ = e.Paralyze(value(b*10), value(b*10+1))
+		}()
+	}
+
+	s.WaitForTasks(4)
+	held := s.Held()
+	s.CompleteTask(held[3])
+	s.CompleteAll()
+	wg.Wait()
+
+	assert.ElementsMatch(t, []interface{}{
+		[]interface{}{0, 1},
+		[]interface{}{10, 11},
+	}, results)
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	var fired []string
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "two") })
+	c.AfterFunc(time.Second, func() { fired = append(fired, "one") })
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	assert.Equal(t, 3, c.Timers())
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	c.Advance(1500 * time.Millisecond)
+	assert.Equal(t, []string{"one"}, fired)
+	assert.Equal(t, start.Add(1500*time.Millisecond), c.Now())
+
+	c.Advance(time.Second)
+	assert.Equal(t, []string{"one", "two"}, fired)
+	assert.Equal(t, 0, c.Timers())
+}
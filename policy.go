@@ -0,0 +1,105 @@
+package paralyze
+
+import "time"
+
+// Policy decides the order in which a Limiter starts the tasks waiting in its
+// queue.
+type Policy interface {
+	// before reports whether a should start before b. It must not depend
+	// on the current time, since the queue is only reordered when tasks
+	// are added or removed.
+	before(a, b *waiter) bool
+}
+
+// FIFO starts tasks in the order they were submitted.
+func FIFO() Policy { return fifo{} }
+
+type fifo struct{}
+
+func (fifo) before(a, b *waiter) bool { return a.seq < b.seq }
+
+// ByPriority starts tasks with a higher Priority first, and tasks with the
+// same priority in the order they were submitted. To keep a stream of high
+// priority tasks from starving the rest, a task gains one level of priority
+// for each aging it spends waiting; zero disables aging.
+func ByPriority(aging time.Duration) Policy { return byPriority{aging} }
+
+type byPriority struct {
+	aging time.Duration
+}
+
+func (p byPriority) before(a, b *waiter) bool {
+	pa, pb := float64(a.task.Priority), float64(b.task.Priority)
+	if p.aging > 0 {
+		// A task's priority after waiting until now is Priority +
+		// (now-at)/aging. Every task ages at the same rate, so comparing
+		// Priority - at/aging gives the same answer at any time.
+		pa -= float64(a.at) / float64(p.aging)
+		pb -= float64(b.at) / float64(p.aging)
+	}
+	if pa != pb {
+		return pa > pb
+	}
+	return a.seq < b.seq
+}
+
+// EarliestDeadline starts tasks with the earliest Deadline first, then tasks
+// without one in the order they were submitted. Tasks whose deadline has
+// passed by the time they would start are dropped with ErrTimedOut instead.
+func EarliestDeadline() Policy { return edf{} }
+
+type edf struct{}
+
+func (edf) before(a, b *waiter) bool {
+	da, db := a.task.Deadline, b.task.Deadline
+	switch {
+	case da.IsZero() && db.IsZero():
+	case da.IsZero():
+		return false
+	case db.IsZero():
+		return true
+	case !da.Equal(db):
+		return da.Before(db)
+	}
+	return a.seq < b.seq
+}
+
+func (edf) expired(w *waiter, now time.Time) bool {
+	return !w.task.Deadline.IsZero() && !now.Before(w.task.Deadline)
+}
+
+// preparer is implemented by policies that work out what they order tasks by
+// when they're submitted, since it mustn't change while they wait.
+type preparer interface {
+	prepare(w *waiter)
+}
+
+// expirer is implemented by policies that drop tasks which are too late to
+// start.
+type expirer interface {
+	expired(w *waiter, now time.Time) bool
+}
+
+// LongestFirst starts the tasks expected to take longest first, so a long task
+// doesn't start last and hold up the end of the batch. A task's expected
+// duration is its Cost, or else what est has learned for its Name; tasks
+// with neither go last, in the order they were submitted. est may be nil.
+func LongestFirst(est *Estimates) Policy { return longestFirst{est} }
+
+type longestFirst struct {
+	est *Estimates
+}
+
+func (p longestFirst) prepare(w *waiter) {
+	w.cost = w.task.Cost
+	if w.cost == 0 && p.est != nil {
+		w.cost, _ = p.est.Expected(w.task.Name)
+	}
+}
+
+func (longestFirst) before(a, b *waiter) bool {
+	if a.cost != b.cost {
+		return a.cost > b.cost
+	}
+	return a.seq < b.seq
+}
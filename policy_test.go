@@ -0,0 +1,123 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFO(t *testing.T) {
+	a := &waiter{task: &Task{Priority: 0}, seq: 1}
+	b := &waiter{task: &Task{Priority: 5}, seq: 2}
+	assert.True(t, FIFO().before(a, b))
+	assert.False(t, FIFO().before(b, a))
+}
+
+func TestByPriority(t *testing.T) {
+	low := &waiter{task: &Task{Priority: 0}, seq: 1}
+	high := &waiter{task: &Task{Priority: 5}, seq: 2}
+	same := &waiter{task: &Task{Priority: 5}, seq: 3}
+
+	p := ByPriority(0)
+	assert.True(t, p.before(high, low))
+	assert.True(t, p.before(high, same))
+	assert.False(t, p.before(same, high))
+}
+
+func TestByPriorityAging(t *testing.T) {
+	clock := &manualClock{}
+	l := NewLimiter(1, Schedule(ByPriority(time.Second)), LimiterClock(clock))
+
+	var order []int
+	submit := func(priority int) {
+		w := &waiter{task: &Task{Priority: priority}, weight: 1}
+		w.grant = func() { order = append(order, priority) }
+		l.submit(w)
+	}
+
+	submit(10)
+	submit(0)
+	clock.advance(3 * time.Second)
+	submit(2)
+	clock.advance(time.Second)
+	submit(4)
+
+	// By now the task submitted with priority 0 has aged to 4, level with
+	// the last one and ahead of the one with priority 2, which has only
+	// aged to 3.
+	for len(order) < 4 {
+		l.release(&waiter{weight: 1})
+	}
+	assert.Equal(t, []int{10, 0, 4, 2}, order)
+}
+
+func TestEarliestDeadline(t *testing.T) {
+	now := time.Now()
+	soon := &waiter{task: &Task{Deadline: now.Add(time.Second)}, seq: 3}
+	later := &waiter{task: &Task{Deadline: now.Add(time.Minute)}, seq: 1}
+	none := &waiter{task: &Task{}, seq: 2}
+
+	p := EarliestDeadline()
+	assert.True(t, p.before(soon, later))
+	assert.True(t, p.before(later, none))
+	assert.False(t, p.before(none, soon))
+}
+
+func TestEarliestDeadlineRun(t *testing.T) {
+	clock := &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(1, Schedule(EarliestDeadline()), LimiterClock(clock))
+	e := NewExecutor(WithLimiter(l))
+
+	release := make(chan struct{})
+	var order []string
+	task := func(name string, deadline time.Duration) Task {
+		t := Task{Fn: func(context.Context) (interface{}, error) {
+			if name == "first" {
+				<-release
+			}
+			order = append(order, name)
+			return name, nil
+		}}
+		if deadline > 0 {
+			t.Deadline = clock.Now().Add(deadline)
+		}
+		return t
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.Run(context.Background(), 0,
+			task("first", 0),
+			task("none", 0),
+			task("hour", time.Hour),
+			task("second", time.Second),
+			task("minute", time.Minute),
+		)
+		assert.Equal(t, []interface{}{"first", "none", "hour", nil, "minute"}, results)
+		assert.Equal(t, []error{nil, nil, nil, ErrTimedOut, nil}, errs)
+	}()
+
+	// Time passes while the first task runs, so the one due in a second is
+	// too late by the time it would start.
+	waitForQueued(t, l, 4)
+	clock.advance(2 * time.Second)
+	close(release)
+	<-done
+	assert.Equal(t, []string{"first", "minute", "hour", "none"}, order)
+}
+
+func TestEarliestDeadlineExpiredOnSubmit(t *testing.T) {
+	clock := &manualClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(1, Schedule(EarliestDeadline()), LimiterClock(clock))
+
+	var got error
+	w := &waiter{task: &Task{Deadline: clock.Now()}, weight: 1}
+	w.grant = func() { t.Fatal("expired task granted") }
+	w.reject = func(err error) { got = err }
+	l.submit(w)
+	assert.Equal(t, ErrTimedOut, got)
+	assert.Equal(t, 0, l.Running())
+}
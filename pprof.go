@@ -0,0 +1,39 @@
+package paralyze
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+)
+
+// Labels set on tasks run by an Executor created with WithPprofLabels.
+const (
+	PprofLabelBatch = "paralyze.batch"
+	PprofLabelTask  = "paralyze.task"
+	PprofLabelKey   = "paralyze.key"
+)
+
+// WithPprofLabels makes an Executor run each task under runtime/pprof.Do, so
+// CPU and goroutine profiles can be broken down by batch and task. Tasks are
+// labeled with the batch's label (see WithLabel), the task's index and, for
+// ParalyzeM, its key. Labels are inherited by goroutines the task starts.
+func WithPprofLabels() Option {
+	return func(e *Executor) { e.wrappers = append(e.wrappers, pprofLabels) }
+}
+
+func pprofLabels(b *batch, i int, fn ParalyzableCtx) ParalyzableCtx {
+	labels := []string{PprofLabelTask, strconv.Itoa(i)}
+	if b.label != "" {
+		labels = append(labels, PprofLabelBatch, b.label)
+	}
+	if key := b.key(i); key != "" {
+		labels = append(labels, PprofLabelKey, key)
+	}
+
+	return func(ctx context.Context) (res interface{}, err error) {
+		pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+			res, err = fn(ctx)
+		})
+		return res, err
+	}
+}
@@ -0,0 +1,38 @@
+package paralyze
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPprofLabels(t *testing.T) {
+	e := NewExecutor(WithPprofLabels(), WithLabel("fanout"))
+
+	label := func(name string) ParalyzableCtx {
+		return func(ctx context.Context) (interface{}, error) {
+			v, _ := pprof.Label(ctx, name)
+			return v, nil
+		}
+	}
+	results, _ := e.ParalyzeWithContext(context.Background(),
+		label(PprofLabelBatch),
+		label(PprofLabelTask),
+		label(PprofLabelTask),
+	)
+	assert.Equal(t, []interface{}{"fanout", "1", "2"}, results)
+}
+
+func TestPprofLabelsKey(t *testing.T) {
+	b := &batch{keys: []string{"user", "orders"}}
+	fn := pprofLabels(b, 1, func(ctx context.Context) (interface{}, error) {
+		v, _ := pprof.Label(ctx, PprofLabelKey)
+		return v, nil
+	})
+
+	res, err := fn(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", res)
+}
@@ -0,0 +1,190 @@
+package paralyze
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of how far along a batch is.
+type Progress struct {
+	Label string
+	Total int
+
+	// Completed is the number of tasks that have finished, including the
+	// ones that failed.
+	Completed int
+	Failed    int
+
+	Elapsed time.Duration
+
+	// Throughput is the number of tasks completed per second so far.
+	Throughput float64
+
+	// ETA estimates how long the rest of the batch will take, based on how
+	// long completed tasks took and how many have run at once. It's zero
+	// until the first task completes.
+	ETA time.Duration
+
+	// Done is true for the last report of a batch.
+	Done bool
+}
+
+// WithProgress makes an Executor report each batch's progress to fn: once when
+// it starts, after each task completes, and once when it's done. Calls for a
+// batch are never concurrent, but fn should return quickly since tasks wait
+// for it.
+func WithProgress(fn func(Progress)) Option {
+	p := &progressObserver{fn: fn, batches: make(map[*batch]*progressState)}
+	return func(e *Executor) { e.observers = append(e.observers, p) }
+}
+
+type progressObserver struct {
+	fn func(Progress)
+
+	mu      sync.Mutex
+	batches map[*batch]*progressState
+}
+
+type progressState struct {
+	mu        sync.Mutex
+	starts    []time.Time
+	finished  []bool
+	running   int
+	parallel  int
+	completed int
+	failed    int
+	busy      time.Duration
+}
+
+func (p *progressObserver) state(b *batch) *progressState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.batches[b]
+}
+
+func (p *progressObserver) batchStarted(b *batch) {
+	s := &progressState{
+		starts:   make([]time.Time, b.size),
+		finished: make([]bool, b.size),
+	}
+	p.mu.Lock()
+	p.batches[b] = s
+	p.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.fn(s.progress(b, false))
+}
+
+func (p *progressObserver) taskStarted(b *batch, i int) {
+	s := p.state(b)
+	if s == nil {
+		// The task was abandoned before it got going and the batch is gone.
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished[i] {
+		return
+	}
+	s.starts[i] = b.clock.Now()
+	s.running++
+	if s.running > s.parallel {
+		s.parallel = s.running
+	}
+}
+
+func (p *progressObserver) taskReturned(b *batch, i int) {}
+
+func (p *progressObserver) taskFinished(b *batch, i int, err error) {
+	s := p.state(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.starts[i].IsZero() {
+		s.busy += b.since(s.starts[i])
+		s.running--
+	}
+	s.finished[i] = true
+	s.completed++
+	if err != nil {
+		s.failed++
+	}
+	p.fn(s.progress(b, false))
+}
+
+func (p *progressObserver) taskPanicked(b *batch, i int, r interface{}, stack []byte) {}
+
+func (p *progressObserver) batchFinished(b *batch, errs []error) {
+	p.mu.Lock()
+	s := p.batches[b]
+	delete(p.batches, b)
+	p.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.fn(s.progress(b, true))
+}
+
+// progress must be called with s.mu held.
+func (s *progressState) progress(b *batch, done bool) Progress {
+	pr := Progress{
+		Label:     b.label,
+		Total:     b.size,
+		Completed: s.completed,
+		Failed:    s.failed,
+		Elapsed:   b.since(b.start),
+		Done:      done,
+	}
+	if pr.Elapsed > 0 {
+		pr.Throughput = float64(s.completed) / pr.Elapsed.Seconds()
+	}
+	if s.completed > 0 && s.parallel > 0 && !done {
+		avg := s.busy / time.Duration(s.completed)
+		remaining := time.Duration(b.size - s.completed)
+		pr.ETA = avg * remaining / time.Duration(s.parallel)
+	}
+	return pr
+}
+
+// ProgressWriter returns a function to pass to WithProgress that renders a
+// progress bar to w, redrawing it in place on each report. It's meant for
+// terminals; the line is finished with a newline once the batch is done.
+// Reports from batches running at the same time are written one at a time.
+func ProgressWriter(w io.Writer) func(Progress) {
+	const width = 30
+	var mu sync.Mutex
+	return func(p Progress) {
+		filled := width
+		if p.Total > 0 {
+			filled = width * p.Completed / p.Total
+		}
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+		var line strings.Builder
+		line.WriteString("\r")
+		if p.Label != "" {
+			fmt.Fprintf(&line, "%s ", p.Label)
+		}
+		fmt.Fprintf(&line, "[%s] %d/%d", bar, p.Completed, p.Total)
+		if p.Failed > 0 {
+			fmt.Fprintf(&line, " (%d failed)", p.Failed)
+		}
+		fmt.Fprintf(&line, " %.1f/s", p.Throughput)
+		if p.Done {
+			fmt.Fprintf(&line, " in %v", p.Elapsed.Round(time.Millisecond))
+		} else if p.ETA > 0 {
+			fmt.Fprintf(&line, " eta %v", p.ETA.Round(time.Second))
+		}
+		line.WriteString("\x1b[K")
+		if p.Done {
+			line.WriteString("\n")
+		}
+		mu.Lock()
+		io.WriteString(w, line.String())
+		mu.Unlock()
+	}
+}
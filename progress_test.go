@@ -0,0 +1,92 @@
+package paralyze
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProgress(t *testing.T) {
+	var mu sync.Mutex
+	var reports []Progress
+	e := NewExecutor(WithLabel("uploads"), WithProgress(func(p Progress) {
+		mu.Lock()
+		reports = append(reports, p)
+		mu.Unlock()
+	}))
+
+	sleepFn := func() (interface{}, error) { time.Sleep(10 * time.Millisecond); return nil, nil }
+	e.ParalyzeLimit(2, sleepFn, sleepFn, errFn, sleepFn)
+
+	assert.Equal(t, 6, len(reports))
+	assert.Equal(t, 0, reports[0].Completed)
+	assert.Equal(t, 4, reports[0].Total)
+	assert.Equal(t, "uploads", reports[0].Label)
+
+	last := reports[len(reports)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, 4, last.Completed)
+	assert.Equal(t, 1, last.Failed)
+	assert.True(t, last.Throughput > 0)
+
+	for i, p := range reports[1:5] {
+		assert.Equal(t, i+1, p.Completed)
+		assert.False(t, p.Done)
+	}
+	assert.True(t, reports[1].ETA > 0)
+	assert.Equal(t, time.Duration(0), reports[4].ETA)
+}
+
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	render := ProgressWriter(&buf)
+
+	render(Progress{Label: "uploads", Total: 4, Completed: 2, Failed: 1, Throughput: 2, ETA: 3 * time.Second})
+	assert.Equal(t, "\r"+`uploads [===============               ] 2/4 (1 failed) 2.0/s eta 3s`+"\x1b[K", buf.String())
+
+	buf.Reset()
+	render(Progress{Total: 4, Completed: 4, Throughput: 4, Elapsed: time.Second, Done: true})
+	assert.True(t, strings.HasSuffix(buf.String(), "4/4 4.0/s in 1s\x1b[K\n"))
+}
+
+func TestProgressWriterConcurrentBatches(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewExecutor(WithProgress(ProgressWriter(&buf)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.ParalyzeLimit(2, fastFn, fastFn, fastFn, fastFn)
+		}()
+	}
+	wg.Wait()
+
+	// Every report is written whole, so none is split by another.
+	for _, line := range strings.Split(buf.String(), "\r")[1:] {
+		assert.True(t, strings.HasSuffix(strings.TrimSuffix(line, "\n"), "\x1b[K"), "%q", line)
+	}
+}
+
+func TestWithProgressCanceled(t *testing.T) {
+	var last Progress
+	e := NewExecutor(WithProgress(func(p Progress) { last = p }))
+
+	cancel := make(chan struct{})
+	close(cancel)
+	release := make(chan struct{})
+	defer close(release)
+	e.ParalyzeWithCancel(cancel, func() (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	assert.True(t, last.Done)
+	assert.Equal(t, 1, last.Completed)
+	assert.Equal(t, 1, last.Failed)
+}
@@ -0,0 +1,221 @@
+package paralyze
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TaskState describes where a task is in its lifetime.
+type TaskState string
+
+// The states a task goes through, in order.
+const (
+	TaskQueued  TaskState = "queued"
+	TaskRunning TaskState = "running"
+	TaskDone    TaskState = "done"
+)
+
+// BatchStatus is a snapshot of a batch that is tracked by a Registry.
+type BatchStatus struct {
+	ID      uint64
+	Label   string
+	Kind    string
+	Started time.Time
+	Elapsed time.Duration
+
+	// Returned is true once the call that ran the batch has returned. A
+	// batch stays in the registry after that for as long as any of its tasks
+	// are still running, which happens when they are abandoned by
+	// ParalyzeWithCancel or ParalyzeWithTimeout.
+	Returned bool
+
+	Tasks []TaskStatus
+}
+
+// TaskStatus is a snapshot of a single task in a batch.
+type TaskStatus struct {
+	Index int
+	Key   string
+	State TaskState
+
+	// Elapsed is how long the task has been running, or how long it ran if
+	// it's done. It's zero for queued tasks.
+	Elapsed time.Duration
+}
+
+// Registry keeps track of the batches that are running on the Executors it
+// has been given to with WithRegistry. It can be served over HTTP as JSON and
+// implements expvar.Var, so it can be published with expvar.Publish.
+type Registry struct {
+	mu      sync.Mutex
+	batches map[*batch]*registryEntry
+}
+
+type registryEntry struct {
+	returned bool
+	pending  int
+	tasks    []registryTask
+}
+
+type registryTask struct {
+	state TaskState
+	start time.Time
+	end   time.Time
+
+	// counted is set once the task no longer counts towards its batch's
+	// pending tasks.
+	counted bool
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{batches: make(map[*batch]*registryEntry)}
+}
+
+// WithRegistry makes an Executor record its batches in r while they run.
+func WithRegistry(r *Registry) Option {
+	return func(e *Executor) { e.observers = append(e.observers, r) }
+}
+
+// Batches returns a snapshot of every batch in the registry, oldest first.
+func (r *Registry) Batches() []BatchStatus {
+	r.mu.Lock()
+	statuses := make([]BatchStatus, 0, len(r.batches))
+	for b, entry := range r.batches {
+		now := b.clock.Now()
+		status := BatchStatus{
+			ID:       b.id,
+			Label:    b.label,
+			Kind:     b.kind,
+			Started:  b.start,
+			Elapsed:  now.Sub(b.start),
+			Returned: entry.returned,
+			Tasks:    make([]TaskStatus, len(entry.tasks)),
+		}
+		for i, task := range entry.tasks {
+			ts := TaskStatus{Index: i, Key: b.key(i), State: task.state}
+			switch task.state {
+			case TaskRunning:
+				ts.Elapsed = now.Sub(task.start)
+			case TaskDone:
+				ts.Elapsed = task.end.Sub(task.start)
+			}
+			status.Tasks[i] = ts
+		}
+		statuses = append(statuses, status)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// ServeHTTP writes the registry's batches as JSON.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Batches())
+}
+
+// String returns the registry's batches as JSON, which makes a Registry an
+// expvar.Var.
+func (r *Registry) String() string {
+	b, _ := json.Marshal(r.Batches())
+	return string(b)
+}
+
+func (r *Registry) batchStarted(b *batch) {
+	tasks := make([]registryTask, b.size)
+	for i := range tasks {
+		tasks[i].state = TaskQueued
+	}
+
+	r.mu.Lock()
+	r.batches[b] = &registryEntry{pending: b.size, tasks: tasks}
+	r.mu.Unlock()
+}
+
+func (r *Registry) taskStarted(b *batch, i int) {
+	now := b.clock.Now()
+	r.mu.Lock()
+	if entry := r.batches[b]; entry != nil {
+		entry.tasks[i].state = TaskRunning
+		entry.tasks[i].start = now
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) taskReturned(b *batch, i int) {
+	now := b.clock.Now()
+	r.mu.Lock()
+	if entry := r.batches[b]; entry != nil {
+		entry.tasks[i].state = TaskDone
+		entry.tasks[i].end = now
+		r.settled(b, entry, i)
+	}
+	r.mu.Unlock()
+}
+
+// taskFinished settles tasks that never started, e.g. because they were
+// canceled or rejected while waiting in a Limiter.
+func (r *Registry) taskFinished(b *batch, i int, err error) {
+	r.mu.Lock()
+	if entry := r.batches[b]; entry != nil && entry.tasks[i].state == TaskQueued {
+		entry.tasks[i].state = TaskDone
+		r.settled(b, entry, i)
+	}
+	r.mu.Unlock()
+}
+
+// settled stops counting task i as pending, and forgets its batch once that
+// was the last one and the batch has returned. It must be called with r.mu
+// held.
+func (r *Registry) settled(b *batch, entry *registryEntry, i int) {
+	if entry.tasks[i].counted {
+		return
+	}
+	entry.tasks[i].counted = true
+	entry.pending--
+	if entry.returned && entry.pending == 0 {
+		delete(r.batches, b)
+	}
+}
+
+func (r *Registry) taskPanicked(b *batch, i int, p interface{}, stack []byte) {}
+
+func (r *Registry) batchFinished(b *batch, errs []error) {
+	r.mu.Lock()
+	if entry := r.batches[b]; entry != nil {
+		entry.returned = true
+		if entry.pending == 0 {
+			delete(r.batches, b)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// MarshalJSON encodes durations as strings, e.g. "1.5s", so the output is
+// readable without further processing.
+func (s BatchStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID       uint64       `json:"id"`
+		Label    string       `json:"label,omitempty"`
+		Kind     string       `json:"kind"`
+		Started  time.Time    `json:"started"`
+		Elapsed  string       `json:"elapsed"`
+		Returned bool         `json:"returned"`
+		Tasks    []TaskStatus `json:"tasks"`
+	}{s.ID, s.Label, s.Kind, s.Started, s.Elapsed.String(), s.Returned, s.Tasks})
+}
+
+// MarshalJSON encodes durations as strings, like BatchStatus.MarshalJSON.
+func (s TaskStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Index   int       `json:"index"`
+		Key     string    `json:"key,omitempty"`
+		State   TaskState `json:"state"`
+		Elapsed string    `json:"elapsed"`
+	}{s.Index, s.Key, s.State, s.Elapsed.String()})
+}
@@ -0,0 +1,108 @@
+package paralyze
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	e := NewExecutor(WithRegistry(reg), WithLabel("uploads"))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocked := func() (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.ParalyzeLimit(1, blocked, fastFn)
+	}()
+	<-started
+
+	batches := reg.Batches()
+	assert.Equal(t, 1, len(batches))
+	assert.Equal(t, "uploads", batches[0].Label)
+	assert.Equal(t, "limit", batches[0].Kind)
+	assert.False(t, batches[0].Returned)
+	assert.Equal(t, TaskRunning, batches[0].Tasks[0].State)
+	assert.Equal(t, TaskQueued, batches[0].Tasks[1].State)
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/paralyze", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "uploads", body[0]["label"])
+	assert.Equal(t, "running", body[0]["tasks"].([]interface{})[0].(map[string]interface{})["state"])
+
+	var expvarBody []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(reg.String()), &expvarBody))
+	assert.Equal(t, float64(batches[0].ID), expvarBody[0]["id"])
+
+	close(release)
+	<-done
+	assert.Empty(t, reg.Batches())
+}
+
+func TestRegistryAbandoned(t *testing.T) {
+	reg := NewRegistry()
+	e := NewExecutor(WithRegistry(reg))
+
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	_, errs := e.ParalyzeWithTimeout(10*time.Millisecond, fastFn, func() (interface{}, error) {
+		<-release
+		defer close(returned)
+		return nil, nil
+	})
+	assert.Equal(t, ErrTimedOut, errs[1])
+
+	batches := reg.Batches()
+	assert.Equal(t, 1, len(batches))
+	assert.True(t, batches[0].Returned)
+	assert.Equal(t, TaskDone, batches[0].Tasks[0].State)
+	assert.Equal(t, TaskRunning, batches[0].Tasks[1].State)
+
+	close(release)
+	<-returned
+	for deadline := time.Now().Add(time.Second); len(reg.Batches()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned batch was never removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRegistryTasksThatNeverStart(t *testing.T) {
+	reg := NewRegistry()
+	e := NewExecutor(WithRegistry(reg))
+
+	// The second task is canceled while it waits for the first.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, errs := e.Run(ctx, 1,
+		Task{Fn: func(context.Context) (interface{}, error) {
+			cancel()
+			return nil, nil
+		}},
+		Task{Fn: IgnoreContext(fastFn)},
+	)
+	assert.Equal(t, ErrCanceled, errs[1])
+	assert.Empty(t, reg.Batches())
+
+	// Batches refused after Shutdown don't run at all.
+	assert.NoError(t, e.Shutdown(context.Background()))
+	_, errs = e.Run(context.Background(), 0, Task{Fn: IgnoreContext(fastFn)})
+	assert.Equal(t, ErrShutdown, errs[0])
+	assert.Empty(t, reg.Batches())
+}
@@ -0,0 +1,111 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy decides how an Executor retries failed tasks; see WithRetry.
+type RetryPolicy struct {
+	// Attempts is how many times a task is run at most, counting the first
+	// run. A task is only run once if it's less than 2.
+	Attempts int
+
+	// Backoff is how long to wait before the second attempt. The wait
+	// doubles after each attempt, up to MaxBackoff if it's set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a task that failed with err should be run
+	// again. If it's nil, every error but a cancellation is retried.
+	Retryable func(err error) bool
+
+	// DeadLetters, if not nil, is sent a DeadLetter for every task that
+	// fails for good: on its last attempt, or with an error that isn't
+	// Retryable. Only Tasks have an Input to record, so jobs that are to be
+	// replayed should be run with Run rather than ParalyzeLimit, which
+	// takes the same limit.
+	DeadLetters DeadLetterSink
+}
+
+// WithRetry makes an Executor run tasks that fail again, according to p.
+// Retries happen within the task's run, so a task keeps its place in any
+// Limiter while it waits to be retried. Tasks aren't retried once their
+// context is done, and panics are never retried.
+func WithRetry(p RetryPolicy) Option {
+	return func(e *Executor) {
+		e.wrappers = append(e.wrappers, func(b *batch, i int, fn ParalyzableCtx) ParalyzableCtx {
+			return func(ctx context.Context) (interface{}, error) {
+				return p.run(ctx, b, i, fn)
+			}
+		})
+	}
+}
+
+func (p *RetryPolicy) run(ctx context.Context, b *batch, i int, fn ParalyzableCtx) (interface{}, error) {
+	var history []Attempt
+	backoff := p.Backoff
+	for n := 1; ; n++ {
+		start := b.clock.Now()
+		res, err := fn(ctx)
+		if err == nil || isCanceled(err) {
+			return res, err
+		}
+		history = append(history, Attempt{Start: start, Duration: b.since(start), Err: err.Error()})
+
+		if n >= p.Attempts || !p.retryable(err) {
+			return res, p.deadLetter(b, i, err, history)
+		}
+		if !sleep(ctx, b.clock, backoff) {
+			return res, err
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// deadLetter sends task i to the policy's DeadLetters, and returns err, along
+// with the sink's error if it has one.
+func (p *RetryPolicy) deadLetter(b *batch, i int, err error, history []Attempt) error {
+	if p.DeadLetters == nil {
+		return err
+	}
+	l := DeadLetter{
+		Key:      b.key(i),
+		Index:    i,
+		Input:    b.input(i),
+		Err:      err.Error(),
+		Attempts: history,
+		Time:     b.clock.Now(),
+	}
+	if serr := p.DeadLetters.Put(l); serr != nil {
+		return errors.Join(err, serr)
+	}
+	return err
+}
+
+// sleep waits for d on c, and reports false if ctx is done first.
+func sleep(ctx context.Context, c Clock, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	done := make(chan struct{})
+	t := c.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		t.Stop()
+		return false
+	}
+}
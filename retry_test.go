@@ -0,0 +1,98 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitClock records the waits it's asked for, and ends them right away.
+type waitClock struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *waitClock) Now() time.Time { return time.Now() }
+
+func (c *waitClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	return time.AfterFunc(0, f)
+}
+
+// failTimes returns a task that fails n times before succeeding, counting
+// its runs in calls.
+func failTimes(n int32, calls *int32) ParalyzableCtx {
+	return func(context.Context) (interface{}, error) {
+		if atomic.AddInt32(calls, 1) <= n {
+			return nil, someError
+		}
+		return "ok", nil
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	clock := &waitClock{}
+	e := NewExecutor(WithClock(clock), WithRetry(RetryPolicy{
+		Attempts:   5,
+		Backoff:    10 * time.Millisecond,
+		MaxBackoff: 30 * time.Millisecond,
+	}))
+
+	var calls int32
+	results, errs := e.Run(context.Background(), 0, Task{Fn: failTimes(4, &calls)})
+	assert.NoError(t, errs[0])
+	assert.Equal(t, "ok", results[0])
+	assert.Equal(t, int32(5), calls)
+	assert.Equal(t, []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		30 * time.Millisecond,
+	}, clock.waits)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	e := NewExecutor(WithRetry(RetryPolicy{Attempts: 3}))
+	_, errs := e.ParalyzeLimit(2, fastFn, func() (interface{}, error) {
+		return failTimes(10, &calls)(context.Background())
+	})
+	assert.NoError(t, errs[0])
+	assert.Equal(t, someError, errs[1])
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	permanent := errors.New("permanent")
+	var calls int32
+	e := NewExecutor(WithRetry(RetryPolicy{
+		Attempts:  3,
+		Retryable: func(err error) bool { return err != permanent },
+	}))
+	_, errs := e.Run(context.Background(), 0, Task{Fn: func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, permanent
+	}})
+	assert.Equal(t, permanent, errs[0])
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	e := NewExecutor(WithRetry(RetryPolicy{Attempts: 3, Backoff: time.Hour}))
+	_, errs := e.Run(ctx, 0, Task{Fn: func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+		return nil, someError
+	}})
+	assert.Equal(t, someError, errs[0])
+	assert.Equal(t, int32(1), calls)
+}
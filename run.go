@@ -0,0 +1,386 @@
+package paralyze
+
+import (
+	"context"
+	"sync"
+)
+
+// runSpec describes how the tasks of a batch are run.
+type runSpec struct {
+	// ctx is passed to the tasks.
+	ctx context.Context
+
+	// cancel is closed to cancel the batch: tasks that haven't started yet
+	// are settled without running. If it's nil, ctx.Done() is used.
+	cancel <-chan struct{}
+
+	// abandon makes running tasks settle as soon as the batch is canceled
+	// too, instead of being waited for.
+	abandon bool
+
+	// canceled is what tasks settle with when the batch is canceled. If it's
+	// nil, it's derived from ctx by canceledErr.
+	canceled error
+
+	// repanic passes a task's panic on to the caller once the batch is
+	// done. Otherwise it crashes the program, like any other goroutine's.
+	repanic bool
+
+	// local is the batch's own Limiter, if it has a limit.
+	local *Limiter
+
+	// refuse, if set, makes every task fail with it without running.
+	refuse error
+}
+
+// run is the state of a batch while its tasks are running.
+type run struct {
+	b      *batch
+	spec   runSpec
+	tasks  []Task
+	caller string
+	done   chan struct{}
+
+	// ctx is passed to the tasks. It's derived from spec.ctx so that
+	// Shutdown can cancel it.
+	ctx     context.Context
+	stopCtx context.CancelCauseFunc
+
+	// gates are the Limiters each task must get through, in order, before
+	// it starts.
+	gates [][]*Limiter
+
+	mu        sync.Mutex
+	results   []interface{}
+	errs      []error
+	settled   []bool
+	running   []bool
+	queued    []slot
+	granted   [][]slot
+	remaining int
+	panik     interface{}
+	watching  bool
+}
+
+// slot is a waiter along with the Limiter it was submitted to.
+type slot struct {
+	l *Limiter
+	w *waiter
+}
+
+// run runs tasks as batch b and waits until each of them has settled.
+func (e *Executor) run(b *batch, tasks []Task, spec runSpec) ([]interface{}, []error) {
+	r := &run{
+		b:         b,
+		spec:      spec,
+		tasks:     tasks,
+		caller:    e.caller,
+		gates:     make([][]*Limiter, len(tasks)),
+		done:      make(chan struct{}),
+		results:   make([]interface{}, len(tasks)),
+		errs:      make([]error, len(tasks)),
+		settled:   make([]bool, len(tasks)),
+		running:   make([]bool, len(tasks)),
+		queued:    make([]slot, len(tasks)),
+		granted:   make([][]slot, len(tasks)),
+		remaining: len(tasks),
+	}
+
+	if r.spec.cancel == nil {
+		r.spec.cancel = spec.ctx.Done()
+	}
+	r.ctx, r.stopCtx = context.WithCancelCause(spec.ctx)
+	if !spec.abandon {
+		// Batches that abandon tasks cancel spec.ctx once they return,
+		// after the tasks have been abandoned, so they see its cause.
+		defer r.stopCtx(nil)
+	}
+
+	// A task waits for its partition before the Executor's Limiter, so it
+	// doesn't hold room there while its partition is full.
+	gated := false
+	for i := range tasks {
+		var gates []*Limiter
+		if spec.local != nil {
+			gates = append(gates, spec.local)
+		}
+		if l := Bulkhead(tasks[i].Partition); l != nil {
+			gates = append(gates, l)
+		}
+		if e.limiter != nil {
+			gates = append(gates, e.limiter)
+		}
+		r.gates[i] = gates
+		gated = gated || len(gates) > 0
+	}
+
+	// Without either, there's nothing to do on cancellation: every task
+	// has already started and will be waited for.
+	r.watching = r.spec.cancel != nil && (spec.abandon || gated)
+
+	b.started()
+	if len(tasks) == 0 {
+		close(r.done)
+	}
+	refuse := spec.refuse
+	if !e.state.add(r) {
+		refuse = ErrShutdown
+	}
+	if refuse != nil {
+		for i := range tasks {
+			r.settle(i, nil, refuse)
+		}
+	}
+	defer e.state.remove(r)
+	for i := range tasks {
+		r.next(i, 0)
+	}
+	if r.watching {
+		// Started after every task has been submitted, so tasks
+		// without gates start even if the batch is already canceled, as
+		// they always have.
+		go r.watch()
+	}
+	<-r.done
+
+	r.mu.Lock()
+	results, errs, panik := r.results, r.errs, r.panik
+	r.mu.Unlock()
+
+	b.finished(errs)
+	if panik != nil {
+		panic(panik)
+	}
+	return results, errs
+}
+
+func (r *run) watch() {
+	select {
+	case <-r.spec.cancel:
+		r.cancel()
+	case <-r.done:
+	}
+}
+
+// cancel settles every task that is waiting to start, and every running one
+// if they are to be abandoned.
+func (r *run) cancel() {
+	err := r.spec.canceled
+	if err == nil {
+		err = canceledErr(r.spec.ctx)
+	}
+	r.stop(err, r.spec.abandon)
+}
+
+// interrupt abandons every task that hasn't settled yet, for Shutdown, and
+// returns their indexes.
+func (r *run) interrupt() []int {
+	r.stopCtx(ErrShutdown)
+	return r.stop(&CanceledError{Err: ErrCanceled, Cause: ErrShutdown}, true)
+}
+
+// stop settles every task that is waiting to start with err, and every
+// running one too if abandon is set. It returns the indexes of the tasks it
+// settled.
+func (r *run) stop(err error, abandon bool) []int {
+	var canceled []int
+	var dequeue []int
+	r.mu.Lock()
+	for i := range r.tasks {
+		if r.settled[i] || (r.running[i] && !abandon) {
+			continue
+		}
+		r.settled[i] = true
+		r.errs[i] = err
+		canceled = append(canceled, i)
+		if r.queued[i].w != nil {
+			dequeue = append(dequeue, i)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, i := range dequeue {
+		r.dequeue(i)
+	}
+	for _, i := range canceled {
+		r.finish(i, err)
+	}
+	return canceled
+}
+
+// next submits task i to the limiter at the given stage, or starts it once
+// it has been through all of them.
+func (r *run) next(i, stage int) {
+	if stage == len(r.gates[i]) {
+		r.launch(i)
+		return
+	}
+
+	l := r.gates[i][stage]
+	w := &waiter{task: &r.tasks[i], weight: r.tasks[i].weight(), caller: r.caller}
+	w.grant = func() { r.grant(i, stage, slot{l, w}) }
+	w.reject = func(err error) { r.reject(i, slot{l, w}, err) }
+
+	r.mu.Lock()
+	if r.settled[i] {
+		r.mu.Unlock()
+		r.release(i)
+		return
+	}
+	r.queued[i] = slot{l, w}
+	r.mu.Unlock()
+
+	l.submit(w)
+
+	// If the task was canceled while it was being submitted, cancel may
+	// have found nothing to take out of the queue.
+	r.mu.Lock()
+	stale := r.settled[i] && r.queued[i].w == w
+	r.mu.Unlock()
+	if stale {
+		r.dequeue(i)
+	}
+}
+
+// grant is called once task i gets room in the limiter at the given stage.
+func (r *run) grant(i, stage int, s slot) {
+	r.mu.Lock()
+	if r.queued[i].w == s.w {
+		r.queued[i] = slot{}
+	}
+	r.granted[i] = append(r.granted[i], s)
+	settled := r.settled[i]
+	r.mu.Unlock()
+
+	if settled {
+		r.release(i)
+		return
+	}
+	r.next(i, stage+1)
+}
+
+// reject is called if the limiter task i was submitted to turns it away.
+func (r *run) reject(i int, s slot, err error) {
+	r.mu.Lock()
+	if r.queued[i].w == s.w {
+		r.queued[i] = slot{}
+	}
+	r.mu.Unlock()
+
+	r.release(i)
+	r.settle(i, nil, err)
+}
+
+// dequeue takes settled task i out of the queue it's waiting in, and gives
+// back the room it got from earlier limiters. If it has been granted in the
+// meantime, grant gives the room back instead.
+func (r *run) dequeue(i int) {
+	r.mu.Lock()
+	s := r.queued[i]
+	r.mu.Unlock()
+
+	if s.w != nil && s.l.remove(s.w) {
+		r.mu.Lock()
+		if r.queued[i].w == s.w {
+			r.queued[i] = slot{}
+		}
+		r.mu.Unlock()
+		r.release(i)
+	}
+}
+
+func (r *run) launch(i int) {
+	r.mu.Lock()
+	if r.settled[i] || r.watching && len(r.gates[i]) > 0 && r.canceled() {
+		// A task that got room in its limiters after the batch was
+		// canceled is left for cancel to settle.
+		r.mu.Unlock()
+		r.release(i)
+		return
+	}
+	r.running[i] = true
+	r.mu.Unlock()
+
+	go r.exec(i)
+}
+
+func (r *run) exec(i int) {
+	if r.spec.repanic {
+		defer func() {
+			if p := recover(); p != nil {
+				r.mu.Lock()
+				if r.panik == nil {
+					r.panik = p
+				}
+				r.mu.Unlock()
+				r.release(i)
+				r.settle(i, nil, nil)
+			}
+		}()
+	}
+	res, err := r.b.call(r.ctx, i, r.tasks[i].Fn)
+	r.finishLimiters(i, err)
+	r.settle(i, res, err)
+}
+
+func (r *run) canceled() bool {
+	select {
+	case <-r.spec.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// release gives back the room task i holds in any limiters, innermost first.
+func (r *run) release(i int) {
+	r.mu.Lock()
+	granted := r.granted[i]
+	r.granted[i] = nil
+	r.mu.Unlock()
+
+	for j := len(granted) - 1; j >= 0; j-- {
+		granted[j].l.release(granted[j].w)
+	}
+}
+
+// finishLimiters is release for a task that ran and returned err.
+func (r *run) finishLimiters(i int, err error) {
+	r.mu.Lock()
+	granted := r.granted[i]
+	r.granted[i] = nil
+	r.mu.Unlock()
+
+	for j := len(granted) - 1; j >= 0; j-- {
+		granted[j].l.finish(granted[j].w, err)
+	}
+}
+
+// settle records the result of task i, unless it has been settled already.
+func (r *run) settle(i int, res interface{}, err error) {
+	r.mu.Lock()
+	if r.settled[i] {
+		r.mu.Unlock()
+		return
+	}
+	r.settled[i] = true
+	r.results[i] = res
+	r.errs[i] = err
+	r.mu.Unlock()
+
+	r.finish(i, err)
+}
+
+// finish reports that task i has settled with err, and ends the batch once
+// every task has.
+func (r *run) finish(i int, err error) {
+	r.b.taskFinished(i, err)
+
+	r.mu.Lock()
+	r.remaining--
+	last := r.remaining == 0
+	r.mu.Unlock()
+	if last {
+		close(r.done)
+	}
+}
@@ -0,0 +1,190 @@
+package paralyze
+
+import (
+	"context"
+	"fmt"
+)
+
+// Step is a task with an action that compensates for it, for RunSaga.
+type Step struct {
+	// Name is passed on as the Name of the Tasks run for the step. The undo
+	// Task is named with "/undo" appended.
+	Name string
+
+	// Do performs the step.
+	Do ParalyzableCtx
+
+	// Undo rolls back a step whose Do succeeded, given what it returned. It
+	// can be nil for steps that have nothing to roll back.
+	Undo func(ctx context.Context, result interface{}) error
+}
+
+// SagaOption configures RunSaga.
+type SagaOption func(*saga)
+
+type saga struct {
+	limit      int
+	reverse    bool
+	compensate func(results []interface{}, errs []error) bool
+}
+
+// SagaLimit runs at most n steps at a time, like the limit of Run. Undo
+// steps run at most n at a time too.
+func SagaLimit(n int) SagaOption {
+	return func(s *saga) { s.limit = n }
+}
+
+// UndoInReverse runs undo steps one at a time, from the last step to the
+// first, rather than all at once.
+func UndoInReverse() SagaOption {
+	return func(s *saga) { s.reverse = true }
+}
+
+// CompensateWhen decides from the steps' results and errors whether to undo
+// the ones that succeeded. By default, they're undone if any step failed.
+func CompensateWhen(fn func(results []interface{}, errs []error) bool) SagaOption {
+	return func(s *saga) { s.compensate = fn }
+}
+
+// SagaError is the error of a saga in which a step failed, or that was
+// compensated.
+type SagaError struct {
+	// Errs are the errors of the steps' Do, in the order of the steps.
+	Errs []error
+
+	// Compensated reports whether the steps that succeeded were undone.
+	Compensated bool
+
+	// UndoErrs are the errors of the steps' Undo, in the order of the
+	// steps. They're nil for steps that weren't undone.
+	UndoErrs []error
+}
+
+func (e *SagaError) Error() string {
+	failed, undoFailed := 0, 0
+	for i := range e.Errs {
+		if e.Errs[i] != nil {
+			failed++
+		}
+		if e.UndoErrs[i] != nil {
+			undoFailed++
+		}
+	}
+	msg := fmt.Sprintf("saga: %d of %d steps failed", failed, len(e.Errs))
+	if e.Compensated {
+		msg += fmt.Sprintf(", %d undone", len(e.Errs)-failed-undoFailed)
+	}
+	if undoFailed > 0 {
+		msg += fmt.Sprintf(", %d failed to undo", undoFailed)
+	}
+	return msg
+}
+
+// Unwrap returns the errors of the steps and their undo steps, so errors.Is
+// and errors.As match any of them.
+func (e *SagaError) Unwrap() []error {
+	var errs []error
+	for _, list := range [][]error{e.Errs, e.UndoErrs} {
+		for _, err := range list {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// RunSaga runs steps in parallel, then undoes the ones that succeeded if any
+// failed, or if the policy set with CompensateWhen says so. Every step runs
+// even once one has failed. Undo steps are passed a context that isn't
+// canceled along with ctx, so a saga that's canceled still rolls back. If a
+// step panics, the steps that succeeded are undone before the panic is
+// passed on.
+//
+// It returns the steps' results, and a SagaError if a step failed or the
+// saga was compensated.
+func (e *Executor) RunSaga(ctx context.Context, steps []Step, opts ...SagaOption) ([]interface{}, error) {
+	s := &saga{compensate: anyFailed}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// done and results record the steps that succeeded, for when Run
+	// panics rather than return them.
+	done := make([]bool, len(steps))
+	results := make([]interface{}, len(steps))
+	tasks := make([]Task, len(steps))
+	for i, step := range steps {
+		i, do := i, step.Do
+		tasks[i] = Task{Name: step.Name, Fn: func(ctx context.Context) (interface{}, error) {
+			res, err := do(ctx)
+			if err == nil {
+				done[i], results[i] = true, res
+			}
+			return res, err
+		}}
+	}
+	undoCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			e.undo(undoCtx, s, steps, results, done, make([]error, len(steps)))
+			panic(r)
+		}
+	}()
+	results, errs := e.Run(ctx, s.limit, tasks...)
+
+	serr := &SagaError{Errs: errs, UndoErrs: make([]error, len(steps))}
+	if s.compensate(results, errs) {
+		serr.Compensated = true
+		for i, err := range errs {
+			done[i] = err == nil
+		}
+		e.undo(undoCtx, s, steps, results, done, serr.UndoErrs)
+	}
+	if !serr.Compensated && len(serr.Unwrap()) == 0 {
+		return results, nil
+	}
+	return results, serr
+}
+
+// undo runs the undo steps of the steps that are done, recording their
+// errors in errs.
+func (e *Executor) undo(ctx context.Context, s *saga, steps []Step, results []interface{}, done []bool, errs []error) {
+	var idx []int
+	var tasks []Task
+	for i, step := range steps {
+		if !done[i] || step.Undo == nil {
+			continue
+		}
+		step, result := step, results[i]
+		name := ""
+		if step.Name != "" {
+			name = step.Name + "/undo"
+		}
+		idx = append(idx, i)
+		tasks = append(tasks, Task{Name: name, Fn: func(ctx context.Context) (interface{}, error) {
+			return nil, step.Undo(ctx, result)
+		}})
+	}
+
+	if !s.reverse {
+		_, undoErrs := e.Run(ctx, s.limit, tasks...)
+		for j, err := range undoErrs {
+			errs[idx[j]] = err
+		}
+		return
+	}
+	for j := len(tasks) - 1; j >= 0; j-- {
+		_, undoErrs := e.Run(ctx, 0, tasks[j])
+		errs[idx[j]] = undoErrs[0]
+	}
+}
+
+func anyFailed(_ []interface{}, errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
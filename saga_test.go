@@ -0,0 +1,170 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resources records the resources created and removed by testSteps.
+type resources struct {
+	mu      sync.Mutex
+	created map[string]bool
+	undone  []string
+}
+
+func (r *resources) step(name string, err error) Step {
+	return Step{
+		Name: name,
+		Do: func(context.Context) (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.created[name] = true
+			return name + "-id", nil
+		},
+		Undo: func(_ context.Context, result interface{}) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if result != name+"-id" {
+				return errors.New("wrong result")
+			}
+			delete(r.created, name)
+			r.undone = append(r.undone, name)
+			return nil
+		},
+	}
+}
+
+func newResources() *resources {
+	return &resources{created: make(map[string]bool)}
+}
+
+func TestSagaSucceeds(t *testing.T) {
+	r := newResources()
+	results, err := RunSaga(context.Background(), []Step{r.step("a", nil), r.step("b", nil)})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a-id", "b-id"}, results)
+	assert.Len(t, r.created, 2)
+	assert.Empty(t, r.undone)
+}
+
+func TestSagaCompensates(t *testing.T) {
+	r := newResources()
+	_, err := RunSaga(context.Background(), []Step{
+		r.step("a", nil),
+		r.step("b", someError),
+		r.step("c", nil),
+	})
+
+	var serr *SagaError
+	assert.True(t, errors.As(err, &serr))
+	assert.True(t, serr.Compensated)
+	assert.Equal(t, []error{nil, someError, nil}, serr.Errs)
+	assert.Equal(t, []error{nil, nil, nil}, serr.UndoErrs)
+	assert.True(t, errors.Is(err, someError))
+	assert.Equal(t, "saga: 1 of 3 steps failed, 2 undone", err.Error())
+	assert.Empty(t, r.created)
+	assert.ElementsMatch(t, []string{"a", "c"}, r.undone)
+}
+
+func TestSagaUndoInReverse(t *testing.T) {
+	r := newResources()
+	_, err := RunSaga(context.Background(), []Step{
+		r.step("a", nil),
+		r.step("b", nil),
+		r.step("c", nil),
+		r.step("d", someError),
+	}, UndoInReverse(), SagaLimit(2))
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"c", "b", "a"}, r.undone)
+}
+
+func TestSagaUndoErrors(t *testing.T) {
+	undoErr := errors.New("undo failed")
+	r := newResources()
+	failing := r.step("b", nil)
+	failing.Undo = func(context.Context, interface{}) error { return undoErr }
+
+	_, err := RunSaga(context.Background(), []Step{
+		r.step("a", nil),
+		failing,
+		{Name: "no undo", Do: IgnoreContext(fastFn)},
+		r.step("c", someError),
+	})
+
+	var serr *SagaError
+	assert.True(t, errors.As(err, &serr))
+	assert.Equal(t, []error{nil, undoErr, nil, nil}, serr.UndoErrs)
+	assert.True(t, errors.Is(err, undoErr))
+	assert.True(t, errors.Is(err, someError))
+	assert.Equal(t, "saga: 1 of 4 steps failed, 2 undone, 1 failed to undo", err.Error())
+}
+
+func TestSagaCompensateWhen(t *testing.T) {
+	// Compensate only when most steps failed.
+	most := CompensateWhen(func(_ []interface{}, errs []error) bool {
+		failed := 0
+		for _, err := range errs {
+			if err != nil {
+				failed++
+			}
+		}
+		return failed*2 > len(errs)
+	})
+
+	r := newResources()
+	_, err := RunSaga(context.Background(), []Step{r.step("a", nil), r.step("b", nil), r.step("c", someError)}, most)
+	var serr *SagaError
+	assert.True(t, errors.As(err, &serr))
+	assert.False(t, serr.Compensated)
+	assert.Empty(t, r.undone)
+
+	r = newResources()
+	_, err = RunSaga(context.Background(), []Step{r.step("a", nil), r.step("b", someError), r.step("c", someError)}, most)
+	assert.True(t, errors.As(err, &serr))
+	assert.True(t, serr.Compensated)
+	assert.Equal(t, []string{"a"}, r.undone)
+}
+
+func TestSagaUndoesWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	undone := false
+	_, err := RunSaga(ctx, []Step{
+		{
+			Do: func(context.Context) (interface{}, error) { return nil, nil },
+			Undo: func(ctx context.Context, _ interface{}) error {
+				undone = true
+				return ctx.Err()
+			},
+		},
+		{Do: func(context.Context) (interface{}, error) {
+			cancel()
+			return nil, context.Canceled
+		}},
+	}, UndoInReverse())
+
+	var serr *SagaError
+	assert.True(t, errors.As(err, &serr))
+	assert.True(t, undone)
+	assert.Equal(t, []error{nil, nil}, serr.UndoErrs)
+}
+
+func TestSagaUndoesOnPanic(t *testing.T) {
+	r := newResources()
+	assert.PanicsWithValue(t, "boom", func() {
+		RunSaga(context.Background(), []Step{
+			r.step("a", nil),
+			{Name: "b", Do: func(context.Context) (interface{}, error) { panic("boom") }},
+			r.step("c", someError),
+		})
+	})
+	assert.Empty(t, r.created)
+	assert.Equal(t, []string{"a"}, r.undone)
+}
@@ -0,0 +1,55 @@
+package paralyze
+
+import "time"
+
+// MaxQueue makes a Limiter reject tasks with ErrRejected rather than queue
+// them once n tasks are already waiting. With n set to zero, tasks that can't
+// start right away are rejected.
+func MaxQueue(n int) LimiterOption {
+	return func(l *Limiter) { l.maxQueue = n }
+}
+
+// MaxWait makes a Limiter reject tasks with ErrRejected once they have waited
+// for d without starting.
+func MaxWait(d time.Duration) LimiterOption {
+	return func(l *Limiter) { l.maxWait = d }
+}
+
+// Quota makes a Limiter reject tasks with ErrRejected once their caller,
+// set with WithCaller, has n tasks waiting or running in it, so one caller
+// can't take up the whole Limiter. Tasks without a caller aren't counted.
+func Quota(n int) LimiterOption {
+	return func(l *Limiter) { l.quota = n }
+}
+
+// CoDel makes a Limiter shed load in the style of the CoDel queue management
+// algorithm: once tasks have been starting after waiting longer than target
+// for at least interval, the queue is considered to be standing rather than
+// absorbing a burst, and tasks that waited longer than target are rejected
+// with ErrRejected instead of started, until one gets through in time.
+func CoDel(target, interval time.Duration) LimiterOption {
+	return func(l *Limiter) { l.codel = &codel{target: target, interval: interval} }
+}
+
+type codel struct {
+	target   time.Duration
+	interval time.Duration
+
+	// above is when waits first went over target, or zero if they're
+	// under it.
+	above time.Time
+}
+
+// shed reports whether a task that waited for sojourn should be rejected as
+// it leaves the queue at now. It must be called with the Limiter's lock held.
+func (c *codel) shed(sojourn time.Duration, now time.Time) bool {
+	if sojourn < c.target {
+		c.above = time.Time{}
+		return false
+	}
+	if c.above.IsZero() {
+		c.above = now
+		return false
+	}
+	return now.Sub(c.above) >= c.interval
+}
@@ -0,0 +1,140 @@
+package paralyze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitForRejected waits until l has rejected n tasks.
+func waitForRejected(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); l.Rejected() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d tasks rejected, want %d", l.Rejected(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxQueue(t *testing.T) {
+	l := NewLimiter(1, MaxQueue(1))
+	e := NewExecutor(WithLimiter(l))
+
+	release := make(chan struct{})
+	blocked := func() (interface{}, error) {
+		<-release
+		return 1, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.Paralyze(blocked, fastFn, fastFn)
+		assert.Equal(t, []interface{}{1, 55, nil}, results)
+		assert.Equal(t, []error{nil, nil, ErrRejected}, errs)
+	}()
+
+	waitForRejected(t, l, 1)
+	assert.Equal(t, 1, l.Queued())
+	close(release)
+	<-done
+	assert.Equal(t, 0, l.Running())
+}
+
+func TestMaxWait(t *testing.T) {
+	clock := &manualClock{}
+	l := NewLimiter(1, MaxWait(time.Second), LimiterClock(clock))
+
+	var got []string
+	submit := func(name string) *waiter {
+		w := &waiter{task: &Task{}, weight: 1}
+		w.grant = func() { got = append(got, name+" granted") }
+		w.reject = func(err error) { got = append(got, name+" "+err.Error()) }
+		l.submit(w)
+		return w
+	}
+
+	first := submit("first")
+	submit("second")
+	clock.advance(time.Second)
+	clock.fire()
+	assert.Equal(t, []string{"first granted", "second rejected"}, got)
+	assert.Equal(t, 0, l.Queued())
+
+	l.release(first)
+	assert.Equal(t, 0, l.Running())
+	assert.Equal(t, 1, l.Rejected())
+}
+
+func TestQuota(t *testing.T) {
+	l := NewLimiter(10, Quota(2))
+	noisy := NewExecutor(WithLimiter(l), WithCaller("noisy"))
+	quiet := NewExecutor(WithLimiter(l), WithCaller("quiet"))
+
+	release := make(chan struct{})
+	blocked := func(context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, errs := noisy.ParalyzeWithContext(context.Background(), blocked, blocked, blocked)
+		assert.Equal(t, []error{nil, nil, ErrRejected}, errs)
+	}()
+
+	waitForRejected(t, l, 1)
+	_, errs := quiet.Paralyze(fastFn, fastFn)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	close(release)
+	<-done
+	_, errs = noisy.Paralyze(fastFn)
+	assert.Equal(t, []error{nil}, errs)
+}
+
+func TestCoDel(t *testing.T) {
+	c := &codel{target: 10 * time.Millisecond, interval: 100 * time.Millisecond}
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	assert.False(t, c.shed(time.Millisecond, at(0)))
+	// A burst: waits go over target, but not for long enough.
+	assert.False(t, c.shed(20*time.Millisecond, at(10*time.Millisecond)))
+	assert.False(t, c.shed(20*time.Millisecond, at(50*time.Millisecond)))
+	assert.False(t, c.shed(time.Millisecond, at(60*time.Millisecond)))
+
+	// A standing queue.
+	assert.False(t, c.shed(20*time.Millisecond, at(100*time.Millisecond)))
+	assert.True(t, c.shed(20*time.Millisecond, at(200*time.Millisecond)))
+	assert.True(t, c.shed(20*time.Millisecond, at(250*time.Millisecond)))
+	assert.False(t, c.shed(5*time.Millisecond, at(260*time.Millisecond)))
+}
+
+func TestCoDelLimiter(t *testing.T) {
+	clock := &manualClock{}
+	l := NewLimiter(1, CoDel(time.Millisecond, time.Second), LimiterClock(clock))
+
+	var got []string
+	submit := func(name string) *waiter {
+		w := &waiter{task: &Task{}, weight: 1}
+		w.grant = func() { got = append(got, name) }
+		w.reject = func(err error) { got = append(got, name+" rejected") }
+		l.submit(w)
+		return w
+	}
+
+	a := submit("a")
+	submit("b")
+	submit("c")
+	clock.advance(time.Second)
+	l.release(a) // b waited too long, but that's the first sign of trouble
+	clock.advance(time.Second)
+	submit("d")
+	l.release(&waiter{weight: 1}) // still too long, so c is shed but d just got here
+	assert.Equal(t, []string{"a", "b", "c rejected", "d"}, got)
+}
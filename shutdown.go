@@ -0,0 +1,137 @@
+package paralyze
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ShutdownError is returned by Shutdown when its context is done before every
+// batch has finished.
+type ShutdownError struct {
+	// Interrupted lists the tasks that hadn't finished. They're abandoned
+	// with a CanceledError caused by ErrShutdown, and the contexts passed to
+	// them are canceled with ErrShutdown as the cause.
+	Interrupted []TaskInfo
+
+	// Err is the error of Shutdown's context.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("paralyze: shutdown interrupted %d task(s): %v", len(e.Interrupted), e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// executorState is shared by an Executor and the copies made of it with With.
+type executorState struct {
+	mu       sync.Mutex
+	runs     map[*run]struct{}
+	closed   bool
+	idle     chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newExecutorState() *executorState {
+	return &executorState{
+		runs: make(map[*run]struct{}),
+		idle: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// add reports false if the Executor has been shut down, in which case r
+// mustn't run.
+func (s *executorState) add(r *run) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.runs[r] = struct{}{}
+	return true
+}
+
+func (s *executorState) remove(r *run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.runs[r]; !ok {
+		return
+	}
+	delete(s.runs, r)
+	if s.closed && len(s.runs) == 0 {
+		close(s.idle)
+	}
+}
+
+// Shutdown stops e, and every copy made of it with With, from running new
+// batches: their tasks fail with ErrShutdown straight away. It then waits for
+// the batches that are running to finish. If ctx is done first, it abandons
+// their remaining tasks and returns a ShutdownError listing them. Shutdown
+// can be called more than once, e.g. to interrupt a drain sooner.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	s := e.state
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		if len(s.runs) == 0 {
+			close(s.idle)
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.idle:
+		s.doneOnce.Do(func() { close(s.done) })
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	runs := make([]*run, 0, len(s.runs))
+	for r := range s.runs {
+		runs = append(runs, r)
+	}
+	s.mu.Unlock()
+
+	var interrupted []TaskInfo
+	for _, r := range runs {
+		for _, i := range r.interrupt() {
+			interrupted = append(interrupted, r.b.taskInfo(i))
+		}
+	}
+	<-s.idle
+	s.doneOnce.Do(func() { close(s.done) })
+
+	if len(interrupted) == 0 {
+		return nil
+	}
+	return &ShutdownError{Interrupted: interrupted, Err: ctx.Err()}
+}
+
+// Done returns a channel that's closed once Shutdown has finished for e.
+func (e *Executor) Done() <-chan struct{} {
+	return e.state.done
+}
+
+// OnShutdown returns a function to pass to http.Server.RegisterOnShutdown,
+// which shuts e down once the server starts shutting down, giving running
+// tasks up to timeout to finish. The server doesn't wait for it, so wait on
+// Done after its Shutdown returns:
+//
+//	srv.RegisterOnShutdown(e.OnShutdown(10 * time.Second))
+//	...
+//	srv.Shutdown(ctx)
+//	<-e.Done()
+func (e *Executor) OnShutdown(timeout time.Duration) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		e.Shutdown(ctx)
+	}
+}
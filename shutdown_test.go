@@ -0,0 +1,105 @@
+package paralyze
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	e := NewExecutor()
+	child := e.With(WithLabel("child"))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := e.Paralyze(func() (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		assert.Equal(t, []interface{}{1}, results)
+		assert.Equal(t, []error{nil}, errs)
+	}()
+	<-started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- e.Shutdown(context.Background()) }()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if _, errs := child.Paralyze(fastFn); errs[0] == ErrShutdown {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("new batches still accepted")
+		}
+	}
+	select {
+	case <-e.Done():
+		t.Fatal("done before the running batch finished")
+	default:
+	}
+
+	close(release)
+	<-done
+	assert.NoError(t, <-shutdown)
+	<-e.Done()
+	assert.NoError(t, e.Shutdown(context.Background()))
+}
+
+func TestShutdownInterrupt(t *testing.T) {
+	l := NewLimiter(1)
+	e := NewExecutor(WithLimiter(l))
+
+	causes := make(chan error, 1)
+	blocked := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil, nil
+	}
+
+	done := make(chan []error)
+	go func() {
+		_, errs := e.ParalyzeWithContext(context.Background(), blocked, blocked)
+		done <- errs
+	}()
+	waitForQueued(t, l, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := e.Shutdown(ctx)
+
+	var se *ShutdownError
+	assert.True(t, errors.As(err, &se))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 2, len(se.Interrupted))
+
+	errs := <-done
+	for _, err := range errs {
+		assert.True(t, errors.Is(err, ErrCanceled))
+		assert.True(t, errors.Is(err, ErrShutdown))
+	}
+	assert.Equal(t, ErrShutdown, <-causes)
+	assert.Equal(t, 0, l.Queued())
+}
+
+func TestOnShutdown(t *testing.T) {
+	e := NewExecutor()
+	var srv http.Server
+	srv.RegisterOnShutdown(e.OnShutdown(time.Second))
+
+	assert.NoError(t, srv.Shutdown(context.Background()))
+	select {
+	case <-e.Done():
+	case <-time.After(time.Second):
+		t.Fatal("executor wasn't shut down with the server")
+	}
+	_, errs := e.Paralyze(fastFn)
+	assert.Equal(t, []error{ErrShutdown}, errs)
+}